@@ -127,6 +127,20 @@ type HarborClusterSpec struct {
 	// Storage service configurations. Might be external cloud storage services or inCluster storage (minIO)
 	// +kubebuilder:validation:Required
 	Storage *Storage `json:"storage"`
+
+	// Rotation of the credentials generated for the inCluster services.
+	// A rotation can also be requested at any time by changing the goharbor.io/rotate-credentials annotation.
+	// +optional
+	CredentialRotation *CredentialRotation `json:"credentialRotation,omitempty"`
+}
+
+// CredentialRotationAnnotation requests a rotation of the generated credentials when its value changes.
+const CredentialRotationAnnotation = "goharbor.io/rotate-credentials"
+
+type CredentialRotation struct {
+	// The interval between two rotations, e.g. "2160h" for 90 days.
+	// +kubebuilder:validation:Required
+	Interval metav1.Duration `json:"interval"`
 }
 
 type Storage struct {
@@ -152,24 +166,34 @@ type Storage struct {
 
 	// Oss options.
 	Oss *Oss `json:"oss,omitempty"`
+
+	// The interval of the storage usage report in the status, default is 1h. Set "0s" to disable the report.
+	// The usage is reported for inCluster, and for s3 and oss by listing the objects.
+	// +optional
+	UsageReportInterval *metav1.Duration `json:"usageReportInterval,omitempty"`
 }
 
 type Oss struct {
 	// +kubebuilder:validation:Required
 	AccessKeyId string `json:"accesskeyid"`
-	// +kubebuilder:validation:Required
-	AccessKeySecret string `json:"accesskeysecret"`
+	// Either accesskeysecret or accesskeysecretRef must be provided.
+	AccessKeySecret    string        `json:"accesskeysecret,omitempty"`
+	AccessKeySecretRef *SecretKeyRef `json:"accesskeysecretRef,omitempty"`
 	// +kubebuilder:validation:Required
 	Region string `json:"region"`
 	// +kubebuilder:validation:Required
 	Bucket string `json:"bucket"`
 	// +kubebuilder:validation:Required
-	Endpoint      string `json:"endpoint"`
-	Internal      string `json:"internal,omitempty"`
-	Encrypt       string `json:"encrypt,omitempty"`
-	Secure        string `json:"secure,omitempty"`
-	ChunkSize     string `json:"chunksize,omitempty"`
+	Endpoint  string `json:"endpoint"`
+	Internal  string `json:"internal,omitempty"`
+	Encrypt   string `json:"encrypt,omitempty"`
+	Secure    string `json:"secure,omitempty"`
+	ChunkSize string `json:"chunksize,omitempty"`
+	// The root directory defaults to /<namespace>/<name> of the harbor cluster if createBucket is set.
 	RootDirectory string `json:"rootdirectory,omitempty"`
+	// Create the bucket if it does not exist.
+	// +optional
+	CreateBucket *CreateBucket `json:"createBucket,omitempty"`
 }
 
 type Swift struct {
@@ -177,26 +201,28 @@ type Swift struct {
 	Authurl string `json:"authurl"`
 	// +kubebuilder:validation:Required
 	Username string `json:"username"`
-	// +kubebuilder:validation:Required
-	Password string `json:"password"`
+	// Either password or passwordRef must be provided.
+	Password    string        `json:"password,omitempty"`
+	PasswordRef *SecretKeyRef `json:"passwordRef,omitempty"`
 	// +kubebuilder:validation:Required
 	Container string `json:"container"`
 	// +kubebuilder:validation:Required
 	Region string `json:"region"`
 	// +kubebuilder:validation:Required
-	Tenant              string `json:"tenant"`
-	TenantId            string `json:"tenantid,omitempty"`
-	Domain              string `json:"domain,omitempty"`
-	DomainId            string `json:"domainid,omitempty"`
-	TrustId             string `json:"trustid,omitempty"`
-	InsecureSkipVerify  bool   `json:"insecureskipverify,omitempty"`
-	ChunkSize           string `json:"chunksize,omitempty"`
-	Prefix              string `json:"prefix,omitempty"`
-	SecretKey           string `json:"secretkey,omitempty"`
-	AuthVersion         int    `json:"authversion,omitempty"`
-	EndpointType        string `json:"endpointtype,omitempty"`
-	TempurlContainerkey bool   `json:"tempurlcontainerkey,omitempty"`
-	TempurlMethods      string `json:"tempurlmethods,omitempty"`
+	Tenant              string        `json:"tenant"`
+	TenantId            string        `json:"tenantid,omitempty"`
+	Domain              string        `json:"domain,omitempty"`
+	DomainId            string        `json:"domainid,omitempty"`
+	TrustId             string        `json:"trustid,omitempty"`
+	InsecureSkipVerify  bool          `json:"insecureskipverify,omitempty"`
+	ChunkSize           string        `json:"chunksize,omitempty"`
+	Prefix              string        `json:"prefix,omitempty"`
+	SecretKey           string        `json:"secretkey,omitempty"`
+	SecretKeyRef        *SecretKeyRef `json:"secretkeyRef,omitempty"`
+	AuthVersion         int           `json:"authversion,omitempty"`
+	EndpointType        string        `json:"endpointtype,omitempty"`
+	TempurlContainerkey bool          `json:"tempurlcontainerkey,omitempty"`
+	TempurlMethods      string        `json:"tempurlmethods,omitempty"`
 }
 
 type S3 struct {
@@ -204,10 +230,12 @@ type S3 struct {
 	Region string `json:"region"`
 	// +kubebuilder:validation:Required
 	Bucket string `json:"bucket"`
-	// +kubebuilder:validation:Required
-	AccessKey string `json:"accesskey"`
-	// +kubebuilder:validation:Required
-	SecretKey string `json:"secretkey"`
+	// Either accesskey or accesskeyRef must be provided.
+	AccessKey    string        `json:"accesskey,omitempty"`
+	AccessKeyRef *SecretKeyRef `json:"accesskeyRef,omitempty"`
+	// Either secretkey or secretkeyRef must be provided.
+	SecretKey    string        `json:"secretkey,omitempty"`
+	SecretKeyRef *SecretKeyRef `json:"secretkeyRef,omitempty"`
 	// +kubebuilder:validation:Required
 	RegionEndpoint string `json:"regionendpoint"`
 	Encrypt        bool   `json:"encrypt,omitempty"`
@@ -215,15 +243,36 @@ type S3 struct {
 	Secure         bool   `json:"secure,omitempty"`
 	V4Auth         bool   `json:"v4auth,omitempty"`
 	ChunkSize      string `json:"chunksize,omitempty"`
-	RootDirectory  string `json:"rootdirectory,omitempty"`
-	StorageClass   string `json:"storageclass,omitempty"`
+	// The root directory defaults to /<namespace>/<name> of the harbor cluster if createBucket is set.
+	RootDirectory string `json:"rootdirectory,omitempty"`
+	StorageClass  string `json:"storageclass,omitempty"`
+	// Create the bucket if it does not exist.
+	// +optional
+	CreateBucket *CreateBucket `json:"createBucket,omitempty"`
+}
+
+// CreateBucket is the settings of the bucket created by the operator in the region of the storage,
+// they are applied only when the bucket is created.
+type CreateBucket struct {
+	// Enable the versioning of the bucket.
+	// +optional
+	Versioning bool `json:"versioning,omitempty"`
+	// The default server side encryption of the objects.
+	// +kubebuilder:validation:Enum=AES256;aws:kms
+	// +optional
+	Encryption string `json:"encryption,omitempty"`
+	// The KMS key of the aws:kms encryption, the default KMS key of the account is used if it is empty.
+	// +optional
+	KMSKeyID string `json:"kmsKeyId,omitempty"`
 }
 
 type Gcs struct {
 	// +kubebuilder:validation:Required
 	Bucket string `json:"bucket"`
-	// The base64 encoded json file which contains the key
-	EncodedKey string `json:"encodedkey"`
+	// The base64 encoded json file which contains the key.
+	// Either encodedkey or encodedkeyRef must be provided.
+	EncodedKey    string        `json:"encodedkey,omitempty"`
+	EncodedKeyRef *SecretKeyRef `json:"encodedkeyRef,omitempty"`
 	// +kubebuilder:validation:Required
 	RootDirectory string `json:"rootdirectory"`
 	ChunkSize     string `json:"chunksize,omitempty"`
@@ -232,26 +281,72 @@ type Gcs struct {
 type Azure struct {
 	// +kubebuilder:validation:Required
 	AccountName string `json:"accountname"`
-	// +kubebuilder:validation:Required
-	AccountKey string `json:"accountkey"`
+	// Either accountkey or accountkeyRef must be provided.
+	AccountKey    string        `json:"accountkey,omitempty"`
+	AccountKeyRef *SecretKeyRef `json:"accountkeyRef,omitempty"`
 	// +kubebuilder:validation:Required
 	Container string `json:"container"`
 	Realm     string `json:"realm,omitempty"`
 }
 
+// SecretKeyRef selects a key of a secret holding a storage credential.
+type SecretKeyRef struct {
+	// The name of the secret.
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// The key of the secret to select from.
+	// +kubebuilder:validation:Required
+	Key string `json:"key"`
+	// The namespace of the secret, default is the namespace of the harbor cluster.
+	// Other namespaces must be allowed by the operator with the --secret-namespaces flag.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
 // InCluster of storage.
 type InCluster struct {
 	// inCluster Provider, just support minIO now.
 	Provider string     `json:"provider,omitempty"`
 	Spec     *MinIOSpec `json:"spec,omitempty"`
+	// Replicate the objects of harbor to a remote s3 compatible storage for disaster recovery.
+	// +optional
+	Replication *MinIOReplication `json:"replication,omitempty"`
+}
+
+// MinIOReplication mirrors the buckets of the in-cluster minIO to a bucket of a remote s3 compatible storage.
+type MinIOReplication struct {
+	// The endpoint of the remote storage, such as "https://s3.us-west-2.amazonaws.com".
+	// TLS is used unless the scheme is http.
+	// +kubebuilder:validation:Required
+	Endpoint string `json:"endpoint"`
+	// +kubebuilder:validation:Required
+	Region string `json:"region"`
+	// +kubebuilder:validation:Required
+	Bucket string `json:"bucket"`
+	// The directory of the remote bucket the objects are replicated to, default is /<namespace>/<name> of the harbor cluster.
+	// +optional
+	RootDirectory string `json:"rootdirectory,omitempty"`
+	// +kubebuilder:validation:Required
+	AccessKeyRef *SecretKeyRef `json:"accesskeyRef"`
+	// +kubebuilder:validation:Required
+	SecretKeyRef *SecretKeyRef `json:"secretkeyRef"`
+	// The interval between the starts of the replication passes, default is 5m.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
 }
 
 type MinIOSpec struct {
 	// Supply number of replicas.
 	// For standalone mode, supply 1. For distributed mode, supply 4 to 16 drives (should be even).
-	// Note that the operator does not support upgrading from standalone to distributed mode.
-	// +kubebuilder:validation:Required
+	// Upgrading from standalone to distributed mode migrates the objects to a new minIO instance.
+	// Ignored if pools are provided.
+	// +optional
 	Replicas int32 `json:"replicas"`
+	// Pools of minIO servers, the capacity is expanded by appending a new pool.
+	// Existing pools can not be changed or removed.
+	// If empty, a single pool is made of replicas and volumeClaimTemplate.
+	// +optional
+	Pools []MinIOPool `json:"pools,omitempty"`
 	// Version defines the MinIO Client (mc) Docker image version.
 	Version string `json:"version,omitempty"`
 	// VolumeClaimTemplate allows a user to specify how volumes inside a MinIOInstance
@@ -260,6 +355,47 @@ type MinIOSpec struct {
 	// If provided, use these requests and limit for cpu/memory resource allocation
 	// +optional
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// If provided, the objects are encrypted at rest with SSE-S3, the object keys are protected by the KMS.
+	// The encryption can not be disabled or moved to another KMS once enabled.
+	// +optional
+	Encryption *MinIOEncryption `json:"encryption,omitempty"`
+}
+
+type MinIOPool struct {
+	// Number of servers of the pool.
+	// The drives of the pool (servers * volumesPerServer) must be a multiple of an erasure set size (4 to 16).
+	// +kubebuilder:validation:Minimum=1
+	Servers int32 `json:"servers"`
+	// Number of volumes per server, default is 1.
+	// Must be the same for all pools as minIO applies it to the whole instance.
+	// +optional
+	VolumesPerServer int `json:"volumesPerServer,omitempty"`
+	// VolumeClaimTemplate of the volumes of the pool.
+	// Must be the same for all pools as minIO applies it to the whole instance.
+	// +optional
+	VolumeClaimTemplate corev1.PersistentVolumeClaim `json:"volumeClaimTemplate,omitempty"`
+}
+
+// GetPools returns the pools of minIO, a single pool is made of replicas and volumeClaimTemplate if pools are not provided.
+func (s *MinIOSpec) GetPools() []MinIOPool {
+	if len(s.Pools) > 0 {
+		return s.Pools
+	}
+	return []MinIOPool{
+		{
+			Servers:             s.Replicas,
+			VolumesPerServer:    1,
+			VolumeClaimTemplate: s.VolumeClaimTemplate,
+		},
+	}
+}
+
+// MinIOEncryption is the KMS of minIO.
+type MinIOEncryption struct {
+	// The key of the secret which contains the master key of minIO, in the format "<key-id>:<hex encoded 256 bit key>".
+	// The secret must be in the namespace of the harbor cluster.
+	// +kubebuilder:validation:Required
+	MasterKeySecret *corev1.SecretKeySelector `json:"masterKeySecret"`
 }
 
 type PostgresSQL struct {
@@ -297,25 +433,236 @@ type Redis struct {
 
 	// +kubebuilder:validation:Required
 	Spec *RedisSpec `json:"spec"`
+
+	// Dedicated redis of some harbor components, e.g. a persistent redis of the jobService queues
+	// and an ephemeral redis of the registry and chartMuseum caches. The other components use the redis above.
+	// +optional
+	Dedicated []DedicatedRedis `json:"dedicated,omitempty"`
+}
+
+// RedisComponentNames are the harbor components using redis.
+var RedisComponentNames = []string{"chartMuseum", "clair", "jobService", "registry"}
+
+// GetSharedComponents returns the harbor components using the redis of .redis.spec, i.e. not a dedicated redis.
+func (r *Redis) GetSharedComponents() []string {
+	dedicated := make(map[string]bool)
+	for _, redis := range r.Dedicated {
+		for _, component := range redis.Components {
+			dedicated[component] = true
+		}
+	}
+
+	var components []string
+	for _, component := range RedisComponentNames {
+		if !dedicated[component] {
+			components = append(components, component)
+		}
+	}
+	return components
+}
+
+// DedicatedRedis is a redis serving some harbor components only.
+type DedicatedRedis struct {
+	// The name of the dedicated redis, the inCluster redis is named after the harbor cluster and this name.
+	// +kubebuilder:validation:Pattern=^[a-z0-9]([-a-z0-9]*[a-z0-9])?$
+	Name string `json:"name"`
+
+	// The harbor components using the dedicated redis: "chartMuseum", "clair", "jobService" or "registry".
+	// A component can only use one dedicated redis.
+	// +kubebuilder:validation:MinItems=1
+	Components []string `json:"components"`
+
+	// Set the kind of the dedicated redis, inCluster or external.
+	// +kubebuilder:validation:Enum=inCluster;external
+	Kind string `json:"kind"`
+
+	// +kubebuilder:validation:Required
+	Spec *RedisSpec `json:"spec"`
 }
 
 type RedisSpec struct {
 	Server   *RedisServer `json:"server,omitempty"`
 	Sentinel *Sentinel    `json:"sentinel,omitempty"`
 
+	// Create an ACL user per harbor component, restricted to the key patterns of the component.
+	// Only supported with the inCluster redis, and requires a redis 6 image set in .server.image.
+	// The passwords of the users are kept in the redis password secret, and are not rotated.
+	// +optional
+	ACLUsers bool `json:"aclUsers,omitempty"`
+
 	// External params following.
-	// The secret must contains "password".
+	// The secret must contains "password", and may contain the redis 6 ACL "username",
+	// and the "sentinelPassword" if the sentinels are secured with another password.
+	// The sentinel password is only used by the operator, harbor components connect to the sentinels without password.
 	SecretName string `json:"secretName,omitempty"`
 	// Maximum number of socket connections.
 	// Default is 10 connections per every CPU as reported by runtime.NumCPU.
+	// Deprecated: use .connection.poolSize, which takes precedence.
 	PoolSize int `json:"poolSize,omitempty"`
 	// TLS Config to use. When set TLS will be negotiated.
-	// set the secret which type of Opaque, and contains "tls.key","tls.crt","ca.crt".
+	// TLS is only supported with an external standalone redis, that is the external kind with the redis schema.
+	// The inCluster redis and the sentinels are served without TLS: the spotahome redis-operator v1.0.0 can not mount
+	// certificates into the redis and sentinel pods, and harbor does not connect to sentinels over TLS.
+	// set the secret which type of Opaque, and contains "ca.crt" to verify the redis server (the system roots are used otherwise),
+	// and "tls.crt","tls.key" if the redis server requires a client certificate. Harbor connects with the rediss scheme.
 	TlsConfig string `json:"tlsConfig,omitempty"`
 	GroupName string `json:"groupName,omitempty"`
+	// The schema of the external redis. A sharded redis cluster is not supported,
+	// since harbor components only connect to a redis server or sentinels.
 	// +kubebuilder:validation:Enum=sentinel;redis
 	Schema string  `json:"schema,omitempty"`
 	Hosts  []Hosts `json:"hosts,omitempty"`
+
+	// The connection profile of the redis clients of the operator, for both kinds.
+	// It is not passed to the harbor components, none of them parses the go-redis parameters of the redis url.
+	// +optional
+	Connection *RedisConnectionProfile `json:"connection,omitempty"`
+
+	// The redis databases and key namespaces of the harbor components.
+	// Every component has its own database by default, so that it can be flushed independently.
+	// +optional
+	Components *RedisComponents `json:"components,omitempty"`
+}
+
+// GetConnectionProfile returns the connection profile of the redis clients,
+// with the pool size of the deprecated .poolSize if not set in the profile.
+func (s *RedisSpec) GetConnectionProfile() *RedisConnectionProfile {
+	profile := &RedisConnectionProfile{}
+	if s.Connection != nil {
+		profile = s.Connection.DeepCopy()
+	}
+	if profile.PoolSize == 0 {
+		profile.PoolSize = s.PoolSize
+	}
+	return profile
+}
+
+// RedisConnectionProfile is the connection pool, timeouts and retries of the redis clients.
+type RedisConnectionProfile struct {
+	// Maximum number of socket connections.
+	// Default is 10 connections per every CPU as reported by runtime.NumCPU.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	PoolSize int `json:"poolSize,omitempty"`
+
+	// Maximum number of retries of a failed command, default is no retry.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxRetries int `json:"maxRetries,omitempty"`
+
+	// The timeout of establishing a connection, default is 10s.
+	// +optional
+	DialTimeout *metav1.Duration `json:"dialTimeout,omitempty"`
+
+	// The timeout of reading a reply, default is 30s.
+	// +optional
+	ReadTimeout *metav1.Duration `json:"readTimeout,omitempty"`
+
+	// The timeout of writing a command, default is 30s.
+	// +optional
+	WriteTimeout *metav1.Duration `json:"writeTimeout,omitempty"`
+
+	// The time waiting for a free connection of the pool when all are busy, default is 30s.
+	// +optional
+	PoolTimeout *metav1.Duration `json:"poolTimeout,omitempty"`
+
+	// The time after which the idle connections are closed, default is 5m.
+	// +optional
+	IdleTimeout *metav1.Duration `json:"idleTimeout,omitempty"`
+}
+
+// The default redis databases of the harbor components, the database 0 is left to harbor core.
+const (
+	DefaultRedisJobServiceDatabase  = 1
+	DefaultRedisRegistryDatabase    = 2
+	DefaultRedisChartMuseumDatabase = 3
+	DefaultRedisClairDatabase       = 4
+)
+
+type RedisComponents struct {
+	// +optional
+	ChartMuseum *RedisComponentCache `json:"chartMuseum,omitempty"`
+	// +optional
+	Clair *RedisComponentCache `json:"clair,omitempty"`
+	// +optional
+	JobService *RedisComponentCache `json:"jobService,omitempty"`
+	// +optional
+	Registry *RedisComponentCache `json:"registry,omitempty"`
+}
+
+// GetComponentCache returns the redis database and key namespace of the harbor component,
+// the component is "chartMuseum", "clair", "jobService" or "registry".
+func (s *RedisSpec) GetComponentCache(component string) (int, string) {
+	cache, database := s.getComponentCache(component)
+	if cache == nil {
+		return database, ""
+	}
+	if cache.Database != nil {
+		database = *cache.Database
+	}
+	return database, cache.Namespace
+}
+
+// GetComponentKeyPatterns returns the key patterns the ACL user of the harbor component is restricted to,
+// the keys of its namespace by default, or every key if it has no namespace.
+func (s *RedisSpec) GetComponentKeyPatterns(component string) []string {
+	cache, _ := s.getComponentCache(component)
+	switch {
+	case cache == nil:
+		return []string{"*"}
+	case len(cache.KeyPatterns) > 0:
+		return cache.KeyPatterns
+	case cache.Namespace != "":
+		return []string{cache.Namespace + "*"}
+	default:
+		return []string{"*"}
+	}
+}
+
+// getComponentCache returns the settings and the default redis database of the harbor component.
+func (s *RedisSpec) getComponentCache(component string) (*RedisComponentCache, int) {
+	var cache *RedisComponentCache
+	var database int
+	switch component {
+	case "chartMuseum":
+		database = DefaultRedisChartMuseumDatabase
+		if s.Components != nil {
+			cache = s.Components.ChartMuseum
+		}
+	case "clair":
+		database = DefaultRedisClairDatabase
+		if s.Components != nil {
+			cache = s.Components.Clair
+		}
+	case "jobService":
+		database = DefaultRedisJobServiceDatabase
+		if s.Components != nil {
+			cache = s.Components.JobService
+		}
+	case "registry":
+		database = DefaultRedisRegistryDatabase
+		if s.Components != nil {
+			cache = s.Components.Registry
+		}
+	}
+	return cache, database
+}
+
+// RedisComponentCache is the redis database and key namespace of a harbor component.
+type RedisComponentCache struct {
+	// The redis database index, defaults to 1 for jobService, 2 for registry, 3 for chartMuseum and 4 for clair.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Database *int `json:"database,omitempty"`
+
+	// The prefix of the keys of the component, e.g. the namespace of the jobService queues.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// The key patterns the ACL user of the component is restricted to, when .redis.spec.aclUsers is enabled.
+	// Defaults to the keys prefixed with the namespace, or every key if the namespace is not set.
+	// +optional
+	KeyPatterns []string `json:"keyPatterns,omitempty"`
 }
 
 type Hosts struct {
@@ -325,6 +672,11 @@ type Hosts struct {
 
 type Sentinel struct {
 	Replicas int `json:"replicas,omitempty"`
+	// The resources of the sentinels, the resources of the redis servers are used if not set.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	RedisPodSettings `json:",inline"`
 }
 
 type RedisServer struct {
@@ -333,10 +685,56 @@ type RedisServer struct {
 	StorageClassName string                      `json:"storageClassName,omitempty"`
 	// the size of storage used in redis.
 	Storage string `json:"storage,omitempty"`
+	// Keep the data of redis in an emptyDir volume instead of a persistent volume, e.g. for a redis of caches only.
+	// The data of a redis pod is lost when it is deleted. It can not be changed once the redis is created.
+	// +optional
+	Ephemeral bool `json:"ephemeral,omitempty"`
+
+	RedisPodSettings `json:",inline"`
+}
+
+// RedisPodSettings are the settings of the pods of the inCluster redis servers or sentinels.
+type RedisPodSettings struct {
+	// The image overriding the default image of the redis operator.
+	// +optional
+	Image string `json:"image,omitempty"`
+	// Configuration lines appended to the redis or sentinel configuration, e.g. "maxmemory-policy allkeys-lru".
+	// +optional
+	CustomConfig []string `json:"customConfig,omitempty"`
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
 }
 
 type ChartMuseum struct {
 	AbsoluteURL bool `json:"absoluteURL,omitempty"`
+
+	// The storage of the charts, separate from the storage of the registry.
+	// The charts are stored along with the registry if it is not set.
+	// +optional
+	Storage *ChartMuseumStorage `json:"storage,omitempty"`
+}
+
+// ChartMuseumStorage is a storage of chartmuseum, which can have another bucket, kind or credentials than the registry.
+type ChartMuseumStorage struct {
+	// Set the kind as "s3", "oss" or "azure", and fill the options of the kind.
+	// +kubebuilder:validation:Enum=s3;oss;azure
+	Kind string `json:"kind"`
+
+	// S3 options. The charts are stored under the root directory of the bucket.
+	// +optional
+	S3 *S3 `json:"s3,omitempty"`
+
+	// Oss options. The charts are stored under the root directory of the bucket.
+	// +optional
+	Oss *Oss `json:"oss,omitempty"`
+
+	// Azure options.
+	// +optional
+	Azure *Azure `json:"azure,omitempty"`
 }
 
 type Trivy struct {
@@ -372,6 +770,180 @@ type HarborClusterStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 	Conditions []HarborClusterCondition `json:"conditions,omitempty"`
+
+	// The last rotation of the generated credentials.
+	// +optional
+	CredentialRotation *CredentialRotationStatus `json:"credentialRotation,omitempty"`
+
+	// The migration of the storage after the storage kind is switched.
+	// +optional
+	StorageMigration *StorageMigrationStatus `json:"storageMigration,omitempty"`
+
+	// The usage of the storage, collected on the usage report interval of the storage.
+	// +optional
+	StorageUsage *StorageUsageStatus `json:"storageUsage,omitempty"`
+
+	// The collection in progress of the usage of the external storage, whose objects are listed across reconciliations.
+	// +optional
+	StorageUsageCollection *StorageUsageCollectionStatus `json:"storageUsageCollection,omitempty"`
+
+	// The replication of the in-cluster minIO to the remote storage.
+	// +optional
+	StorageReplication *StorageReplicationStatus `json:"storageReplication,omitempty"`
+
+	// The last write, read and delete checks of the external buckets,
+	// which are repeated on the probe interval or when the bucket or its credentials change.
+	// +optional
+	StorageProbes []StorageProbeStatus `json:"storageProbes,omitempty"`
+}
+
+type StorageProbeStatus struct {
+	// The checksum of the endpoint, the bucket and the credentials which are checked.
+	Checksum string `json:"checksum"`
+	// Last time an object was written, read and deleted in the bucket.
+	LastProbeTime metav1.Time `json:"lastProbeTime"`
+}
+
+type StorageReplicationStatus struct {
+	// Last time a replication pass completed.
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// The start time of the last completed replication pass,
+	// every object stored in minIO before it is replicated.
+	// +optional
+	LastSyncStartTime *metav1.Time `json:"lastSyncStartTime,omitempty"`
+	// The time elapsed since the start of the last completed replication pass, which bounds the replication lag.
+	// +optional
+	Lag *metav1.Duration `json:"lag,omitempty"`
+	// The start time of the replication pass in progress.
+	// +optional
+	CurrentSyncStartTime *metav1.Time `json:"currentSyncStartTime,omitempty"`
+	// The progress of every replicated bucket in the pass in progress.
+	// +optional
+	Buckets []BucketReplicationStatus `json:"buckets,omitempty"`
+	// The error of the last replication attempt.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+type BucketReplicationStatus struct {
+	// The bucket of the in-cluster minIO.
+	Bucket string `json:"bucket"`
+	// The prefix of the objects in the remote bucket.
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+	// The number and size of the objects copied in the pass, the objects already replicated are skipped.
+	ReplicatedObjects int64 `json:"replicatedObjects"`
+	ReplicatedBytes   int64 `json:"replicatedBytes"`
+	// The last checked object, the pass is resumed after it.
+	// +optional
+	LastKey string `json:"lastKey,omitempty"`
+	// Whether all objects of the bucket are checked in the pass.
+	// +optional
+	Done bool `json:"done,omitempty"`
+}
+
+type StorageUsageStatus struct {
+	// The size of the objects stored by harbor in bytes.
+	UsedBytes int64 `json:"usedBytes"`
+	// The number of the objects stored by harbor.
+	Objects int64 `json:"objects"`
+	// The total size of the drives of the in-cluster minIO in bytes.
+	// +optional
+	RawCapacityBytes int64 `json:"rawCapacityBytes,omitempty"`
+	// The size of the drives of the in-cluster minIO available to objects in bytes, excluding the erasure code parity.
+	// +optional
+	UsableCapacityBytes int64 `json:"usableCapacityBytes,omitempty"`
+	// Last time the usage was collected.
+	LastUpdateTime metav1.Time `json:"lastUpdateTime"`
+}
+
+type StorageUsageCollectionStatus struct {
+	// The start time of the collection.
+	StartTime metav1.Time `json:"startTime"`
+	// The size and the number of the objects listed so far.
+	UsedBytes int64 `json:"usedBytes"`
+	Objects   int64 `json:"objects"`
+	// The last listed object, the collection is resumed after it.
+	// +optional
+	LastKey string `json:"lastKey,omitempty"`
+}
+
+type CredentialRotationStatus struct {
+	// Last time the credentials were rotated.
+	LastRotationTime metav1.Time `json:"lastRotationTime"`
+	// The value of the goharbor.io/rotate-credentials annotation handled by the last rotation.
+	// +optional
+	LastRequest string `json:"lastRequest,omitempty"`
+}
+
+// StorageMigrationPhase is the phase of the migration between storage kinds.
+type StorageMigrationPhase string
+
+const (
+	// StorageMigrationProvisioning means the target minIO instance is being provisioned,
+	// in the migration from a standalone to a distributed in-cluster minIO.
+	StorageMigrationProvisioning StorageMigrationPhase = "Provisioning"
+	// StorageMigrationReadOnly means harbor is being put into read-only mode.
+	StorageMigrationReadOnly StorageMigrationPhase = "ReadOnly"
+	// StorageMigrationCopying means the objects are being copied to the target storage.
+	StorageMigrationCopying StorageMigrationPhase = "Copying"
+	// StorageMigrationVerifying means the copied objects are being compared with the source storage.
+	StorageMigrationVerifying StorageMigrationPhase = "Verifying"
+	// StorageMigrationSwitching means harbor is being switched to the target storage.
+	StorageMigrationSwitching StorageMigrationPhase = "Switching"
+	// StorageMigrationCompleted means harbor uses the target storage and the source storage is released.
+	StorageMigrationCompleted StorageMigrationPhase = "Completed"
+)
+
+type StorageMigrationStatus struct {
+	// The storage kind migrated from.
+	SourceKind string `json:"sourceKind"`
+	// The storage kind migrated to.
+	TargetKind string `json:"targetKind"`
+	// The minIO instance migrated from, in the migration from a standalone to a distributed in-cluster minIO.
+	// +optional
+	SourceInstance string `json:"sourceInstance,omitempty"`
+	// The minIO instance migrated to, in the migration from a standalone to a distributed in-cluster minIO.
+	// +optional
+	TargetInstance string `json:"targetInstance,omitempty"`
+	// The current phase of the migration.
+	Phase StorageMigrationPhase `json:"phase"`
+	// Whether harbor was in read-only mode before the migration, which is restored once the migration completes.
+	// +optional
+	PreviousReadOnly *bool `json:"previousReadOnly,omitempty"`
+	// The progress of every migrated bucket.
+	// +optional
+	Buckets []BucketMigrationStatus `json:"buckets,omitempty"`
+	// The last error of the migration, which is retried.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+type BucketMigrationStatus struct {
+	// The bucket of the source storage.
+	Source string `json:"source"`
+	// The bucket of the target storage.
+	Target string `json:"target"`
+	// The prefix of the objects in the target bucket.
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+	// The number and size of the objects in the source bucket.
+	TotalObjects int64 `json:"totalObjects"`
+	TotalBytes   int64 `json:"totalBytes"`
+	// The number and size of the copied objects.
+	CopiedObjects int64 `json:"copiedObjects"`
+	CopiedBytes   int64 `json:"copiedBytes"`
+	// The last copied object, the copy is resumed after it.
+	// +optional
+	LastKey string `json:"lastKey,omitempty"`
+	// Whether all objects of the bucket are copied.
+	// +optional
+	Done bool `json:"done,omitempty"`
 }
 
 // HarborClusterConditionType is a valid value for HarborClusterConditionType.Type
@@ -0,0 +1,74 @@
+package v1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetComponentCache(t *testing.T) {
+	database := func(d int) *int {
+		return &d
+	}
+
+	spec := &RedisSpec{
+		Components: &RedisComponents{
+			JobService: &RedisComponentCache{Namespace: "{harbor_job_service_namespace}"},
+			Registry:   &RedisComponentCache{Database: database(5)},
+			Clair:      &RedisComponentCache{Database: database(0), Namespace: "clair:"},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		spec          *RedisSpec
+		component     string
+		wantDatabase  int
+		wantNamespace string
+	}{
+		{name: "default jobService", spec: &RedisSpec{}, component: "jobService", wantDatabase: DefaultRedisJobServiceDatabase},
+		{name: "default registry", spec: &RedisSpec{}, component: "registry", wantDatabase: DefaultRedisRegistryDatabase},
+		{name: "default chartMuseum", spec: &RedisSpec{}, component: "chartMuseum", wantDatabase: DefaultRedisChartMuseumDatabase},
+		{name: "default clair", spec: &RedisSpec{}, component: "clair", wantDatabase: DefaultRedisClairDatabase},
+		{name: "namespace only", spec: spec, component: "jobService", wantDatabase: DefaultRedisJobServiceDatabase, wantNamespace: "{harbor_job_service_namespace}"},
+		{name: "database only", spec: spec, component: "registry", wantDatabase: 5},
+		{name: "database 0", spec: spec, component: "clair", wantDatabase: 0, wantNamespace: "clair:"},
+		{name: "unset component", spec: spec, component: "chartMuseum", wantDatabase: DefaultRedisChartMuseumDatabase},
+		{name: "unknown component", spec: spec, component: "core", wantDatabase: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotDatabase, gotNamespace := tt.spec.GetComponentCache(tt.component)
+			if gotDatabase != tt.wantDatabase || gotNamespace != tt.wantNamespace {
+				t.Errorf("GetComponentCache(%s) = %d, %q, want %d, %q", tt.component, gotDatabase, gotNamespace, tt.wantDatabase, tt.wantNamespace)
+			}
+		})
+	}
+}
+
+func TestGetComponentKeyPatterns(t *testing.T) {
+	spec := &RedisSpec{
+		Components: &RedisComponents{
+			JobService:  &RedisComponentCache{Namespace: "{harbor_job_service_namespace}"},
+			Registry:    &RedisComponentCache{Namespace: "registry:", KeyPatterns: []string{"registry:*", "blobs::*"}},
+			ChartMuseum: &RedisComponentCache{},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		component string
+		want      []string
+	}{
+		{name: "namespace", component: "jobService", want: []string{"{harbor_job_service_namespace}*"}},
+		{name: "key patterns take precedence", component: "registry", want: []string{"registry:*", "blobs::*"}},
+		{name: "no namespace", component: "chartMuseum", want: []string{"*"}},
+		{name: "unset component", component: "clair", want: []string{"*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := spec.GetComponentKeyPatterns(tt.component); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetComponentKeyPatterns(%s) = %v, want %v", tt.component, got, tt.want)
+			}
+		})
+	}
+}
@@ -17,7 +17,10 @@ package v1
 
 import (
 	"errors"
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"reflect"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -54,15 +57,46 @@ var _ webhook.Validator = &HarborCluster{}
 func (r *HarborCluster) ValidateCreate() error {
 	harborclusterlog.Info("validate create", "name", r.Name)
 
-	// TODO(user): fill in your validation logic upon object creation.
-	return nil
+	if err := r.ValidateChartMuseumStorage(); err != nil {
+		return err
+	}
+
+	if err := r.ValidateRedis(); err != nil {
+		return err
+	}
+
+	return r.ValidateStorage()
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *HarborCluster) ValidateUpdate(old runtime.Object) error {
 	harborclusterlog.Info("validate update", "name", r.Name)
 
-	return r.ValidateComponentKind(old)
+	if err := r.ValidateComponentKind(old); err != nil {
+		return err
+	}
+
+	if err := r.ValidateMinIOExpansion(old); err != nil {
+		return err
+	}
+
+	if err := r.ValidateRedisEphemeral(old); err != nil {
+		return err
+	}
+
+	if err := r.ValidateStorageRootDirectory(old); err != nil {
+		return err
+	}
+
+	if err := r.ValidateChartMuseumStorage(); err != nil {
+		return err
+	}
+
+	if err := r.ValidateRedis(); err != nil {
+		return err
+	}
+
+	return r.ValidateStorage()
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -76,9 +110,462 @@ func (r *HarborCluster) ValidateDelete() error {
 func (r *HarborCluster) ValidateComponentKind(old runtime.Object) error {
 	oldHarbor := old.(*HarborCluster)
 	if r.Spec.Redis.Kind != oldHarbor.Spec.Redis.Kind ||
-		r.Spec.Database.Kind != oldHarbor.Spec.Database.Kind ||
-		r.Spec.Storage.Kind != oldHarbor.Spec.Storage.Kind {
+		r.Spec.Database.Kind != oldHarbor.Spec.Database.Kind {
 		return errors.New("service kind switching is not supported")
 	}
+
+	for _, dedicated := range r.Spec.Redis.Dedicated {
+		for _, oldDedicated := range oldHarbor.Spec.Redis.Dedicated {
+			if dedicated.Name == oldDedicated.Name && dedicated.Kind != oldDedicated.Kind {
+				return fmt.Errorf("service kind switching of the dedicated redis %s is not supported", dedicated.Name)
+			}
+		}
+	}
+
+	if r.Spec.Storage.Kind != oldHarbor.Spec.Storage.Kind {
+		return r.ValidateStorageMigration(oldHarbor)
+	}
+	return nil
+}
+
+// ValidateRedisEphemeral check that the storage of the existing inCluster redis is not switched between emptyDir and
+// persistent volumes, which the redis-operator can not apply to its statefulset.
+func (r *HarborCluster) ValidateRedisEphemeral(old runtime.Object) error {
+	oldHarbor := old.(*HarborCluster)
+	if r.Spec.Redis.Kind == InClusterComponent && isRedisEphemeral(r.Spec.Redis.Spec) != isRedisEphemeral(oldHarbor.Spec.Redis.Spec) {
+		return errors.New(".redis.spec.server.ephemeral can not be changed")
+	}
+
+	for i, dedicated := range r.Spec.Redis.Dedicated {
+		if dedicated.Kind != InClusterComponent {
+			continue
+		}
+		for _, oldDedicated := range oldHarbor.Spec.Redis.Dedicated {
+			if dedicated.Name == oldDedicated.Name && isRedisEphemeral(dedicated.Spec) != isRedisEphemeral(oldDedicated.Spec) {
+				return fmt.Errorf(".redis.dedicated[%d].spec.server.ephemeral can not be changed", i)
+			}
+		}
+	}
+	return nil
+}
+
+func isRedisEphemeral(spec *RedisSpec) bool {
+	return spec != nil && spec.Server != nil && spec.Server.Ephemeral
+}
+
+// ValidateStorageRootDirectory check that createBucket is not switched on or off for the existing s3 or oss storage
+// without a root directory, which would move the default root directory and leave the existing data behind.
+func (r *HarborCluster) ValidateStorageRootDirectory(old runtime.Object) error {
+	oldHarbor := old.(*HarborCluster)
+	if r.Spec.Storage.Kind != oldHarbor.Spec.Storage.Kind {
+		return nil
+	}
+
+	rootDirectory, createBucket, ok := getBucketOptions(r.Spec.Storage)
+	oldRootDirectory, oldCreateBucket, oldOk := getBucketOptions(oldHarbor.Spec.Storage)
+	if !ok || !oldOk || rootDirectory != "" || oldRootDirectory != "" {
+		return nil
+	}
+
+	if (createBucket == nil) != (oldCreateBucket == nil) {
+		return fmt.Errorf("switching .storage.%s.createBucket without rootdirectory moves the root directory of the existing storage, set rootdirectory to keep it", r.Spec.Storage.Kind)
+	}
+	return nil
+}
+
+// getBucketOptions returns the root directory and the createBucket options of the s3 or oss storage.
+func getBucketOptions(storage *Storage) (string, *CreateBucket, bool) {
+	switch {
+	case storage.Kind == "s3" && storage.S3 != nil:
+		return storage.S3.RootDirectory, storage.S3.CreateBucket, true
+	case storage.Kind == "oss" && storage.Oss != nil:
+		return storage.Oss.RootDirectory, storage.Oss.CreateBucket, true
+	default:
+		return "", nil, false
+	}
+}
+
+// ValidateStorageMigration check that the storage can be migrated from the old kind to the new kind.
+// The in-cluster minIO can be migrated to the external s3 compatible storage, one migration at a time.
+func (r *HarborCluster) ValidateStorageMigration(old *HarborCluster) error {
+	migration := old.Status.StorageMigration
+	if migration != nil && migration.Phase != StorageMigrationCompleted {
+		return errors.New("storage kind can not be switched while a storage migration is in progress")
+	}
+
+	if old.Spec.Storage.Kind != "inCluster" || (r.Spec.Storage.Kind != "s3" && r.Spec.Storage.Kind != "oss") {
+		return fmt.Errorf("storage kind switching from %s to %s is not supported, only inCluster to s3 or oss is supported",
+			old.Spec.Storage.Kind, r.Spec.Storage.Kind)
+	}
+	return nil
+}
+
+// ValidateStorage check that the options of the storage kind are provided, and every required credential
+// of the external storage is set either in plain text or by a secret reference, but not both.
+func (r *HarborCluster) ValidateStorage() error {
+	storage := r.Spec.Storage
+	if storage == nil {
+		return nil
+	}
+
+	switch storage.Kind {
+	case "inCluster":
+		if storage.InCluster == nil {
+			return nil
+		}
+		if err := validateReplication(storage.InCluster.Replication); err != nil {
+			return err
+		}
+		if storage.InCluster.Spec == nil {
+			return nil
+		}
+		if err := validateMinIOPools(storage.InCluster.Spec.GetPools()); err != nil {
+			return err
+		}
+		encryption := storage.InCluster.Spec.Encryption
+		if encryption != nil && encryption.MasterKeySecret == nil {
+			return errors.New(".storage.inCluster.spec.encryption.masterKeySecret is required")
+		}
+	case "azure":
+		if storage.Azure == nil {
+			return errors.New(".storage.azure is required")
+		}
+		return validateCredential("accountkey", storage.Azure.AccountKey, storage.Azure.AccountKeyRef, true)
+	case "gcs":
+		if storage.Gcs == nil {
+			return errors.New(".storage.gcs is required")
+		}
+		return validateCredential("encodedkey", storage.Gcs.EncodedKey, storage.Gcs.EncodedKeyRef, true)
+	case "s3":
+		if storage.S3 == nil {
+			return errors.New(".storage.s3 is required")
+		}
+		if err := validateCreateBucket(storage.S3.CreateBucket); err != nil {
+			return err
+		}
+		if err := validateCredential("accesskey", storage.S3.AccessKey, storage.S3.AccessKeyRef, true); err != nil {
+			return err
+		}
+		return validateCredential("secretkey", storage.S3.SecretKey, storage.S3.SecretKeyRef, true)
+	case "swift":
+		if storage.Swift == nil {
+			return errors.New(".storage.swift is required")
+		}
+		if err := validateCredential("password", storage.Swift.Password, storage.Swift.PasswordRef, true); err != nil {
+			return err
+		}
+		return validateCredential("secretkey", storage.Swift.SecretKey, storage.Swift.SecretKeyRef, false)
+	case "oss":
+		if storage.Oss == nil {
+			return errors.New(".storage.oss is required")
+		}
+		if err := validateCreateBucket(storage.Oss.CreateBucket); err != nil {
+			return err
+		}
+		return validateCredential("accesskeysecret", storage.Oss.AccessKeySecret, storage.Oss.AccessKeySecretRef, true)
+	}
+
+	return nil
+}
+
+// ValidateChartMuseumStorage check that the options of the separate chartmuseum storage kind are provided.
+func (r *HarborCluster) ValidateChartMuseumStorage() error {
+	if r.Spec.ChartMuseum == nil || r.Spec.ChartMuseum.Storage == nil {
+		return nil
+	}
+
+	storage := r.Spec.ChartMuseum.Storage
+	switch storage.Kind {
+	case "s3":
+		if storage.S3 == nil {
+			return errors.New(".chartMuseum.storage.s3 is required")
+		}
+		if storage.S3.CreateBucket != nil {
+			return errors.New(".chartMuseum.storage.s3.createBucket is not supported")
+		}
+		if err := validateCredential("accesskey", storage.S3.AccessKey, storage.S3.AccessKeyRef, true); err != nil {
+			return err
+		}
+		return validateCredential("secretkey", storage.S3.SecretKey, storage.S3.SecretKeyRef, true)
+	case "oss":
+		if storage.Oss == nil {
+			return errors.New(".chartMuseum.storage.oss is required")
+		}
+		if storage.Oss.CreateBucket != nil {
+			return errors.New(".chartMuseum.storage.oss.createBucket is not supported")
+		}
+		return validateCredential("accesskeysecret", storage.Oss.AccessKeySecret, storage.Oss.AccessKeySecretRef, true)
+	case "azure":
+		if storage.Azure == nil {
+			return errors.New(".chartMuseum.storage.azure is required")
+		}
+		// chartmuseum always connects to the public azure cloud.
+		if storage.Azure.Realm != "" && storage.Azure.Realm != "core.windows.net" {
+			return errors.New(".chartMuseum.storage.azure.realm is not supported")
+		}
+		return validateCredential("accountkey", storage.Azure.AccountKey, storage.Azure.AccountKeyRef, true)
+	default:
+		return fmt.Errorf("chartmuseum storage kind %s is not supported", storage.Kind)
+	}
+}
+
+// ValidateRedis check the redis of .redis.spec and the dedicated redis, every harbor component uses a single
+// dedicated redis at most.
+func (r *HarborCluster) ValidateRedis() error {
+	redis := r.Spec.Redis
+	if redis == nil || redis.Spec == nil {
+		return nil
+	}
+
+	if err := validateRedisSpec(".redis.spec", redis.Kind, redis.Spec, redis.GetSharedComponents()); err != nil {
+		return err
+	}
+
+	names := make(map[string]bool)
+	dedicatedComponents := make(map[string]string)
+	for i, dedicated := range redis.Dedicated {
+		path := fmt.Sprintf(".redis.dedicated[%d]", i)
+		if dedicated.Name == "" || names[dedicated.Name] {
+			return fmt.Errorf("%s.name must be set and unique", path)
+		}
+		names[dedicated.Name] = true
+
+		for _, component := range dedicated.Components {
+			if !isRedisComponent(component) {
+				return fmt.Errorf("%s.components: %s is not a harbor component using redis", path, component)
+			}
+			if other, ok := dedicatedComponents[component]; ok {
+				return fmt.Errorf("%s.components: %s already uses the dedicated redis %s", path, component, other)
+			}
+			dedicatedComponents[component] = dedicated.Name
+		}
+
+		if dedicated.Spec == nil {
+			return fmt.Errorf("%s.spec is required", path)
+		}
+		if err := validateRedisSpec(path+".spec", dedicated.Kind, dedicated.Spec, dedicated.Components); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isRedisComponent(component string) bool {
+	for _, name := range RedisComponentNames {
+		if component == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRedisSpec check that the schema is supported by harbor components, and TLS is only enabled for the
+// external redis servers, since the inCluster redis can not serve TLS, and harbor does not connect to redis
+// sentinels over TLS.
+func validateRedisSpec(path, kind string, spec *RedisSpec, components []string) error {
+	// harbor components would fail on the keys served by the other nodes of a redis cluster.
+	if spec.Schema == "cluster" {
+		return fmt.Errorf("%s.schema cluster is not supported, harbor components only connect to a redis server or sentinels", path)
+	}
+
+	if err := validateRedisComponents(path, spec, components); err != nil {
+		return err
+	}
+
+	if err := validateRedisConnection(path, spec.Connection); err != nil {
+		return err
+	}
+
+	if spec.ACLUsers {
+		if kind != InClusterComponent {
+			return fmt.Errorf("%s.aclUsers is only supported with the inCluster redis", path)
+		}
+		// the default image of the redis operator is redis 5, which has no ACL.
+		if spec.Server == nil || spec.Server.Image == "" {
+			return fmt.Errorf("%s.server.image of redis 6 is required with aclUsers", path)
+		}
+	}
+
+	if spec.TlsConfig == "" {
+		return nil
+	}
+	// the redis-operator can not mount certificates into the inCluster redis and sentinel pods.
+	if kind != ExternalComponent {
+		return fmt.Errorf("%s.tlsConfig is only supported with the external redis, the inCluster redis can not be served over TLS", path)
+	}
+	if spec.Schema == "sentinel" {
+		return fmt.Errorf("%s.tlsConfig is not supported with the sentinel schema", path)
+	}
+	return nil
+}
+
+// validateRedisComponents check that every harbor component has its own database of the redis it uses,
+// so that flushing the database of a component does not wipe the keys of the others.
+func validateRedisComponents(path string, spec *RedisSpec, components []string) error {
+	databases := make(map[int]string)
+	for _, component := range components {
+		database, _ := spec.GetComponentCache(component)
+		if other, ok := databases[database]; ok {
+			return fmt.Errorf("%s.components.%s and %s can not share the redis database %d", path, other, component, database)
+		}
+		databases[database] = component
+	}
+	return nil
+}
+
+// validateRedisConnection check that the pool size, retries and timeouts of the connection profile are not negative.
+func validateRedisConnection(path string, profile *RedisConnectionProfile) error {
+	if profile == nil {
+		return nil
+	}
+	if profile.PoolSize < 0 || profile.MaxRetries < 0 {
+		return fmt.Errorf("%s.connection.poolSize and maxRetries can not be negative", path)
+	}
+	for name, duration := range map[string]*metav1.Duration{
+		"dialTimeout":  profile.DialTimeout,
+		"readTimeout":  profile.ReadTimeout,
+		"writeTimeout": profile.WriteTimeout,
+		"poolTimeout":  profile.PoolTimeout,
+		"idleTimeout":  profile.IdleTimeout,
+	} {
+		if duration != nil && duration.Duration < 0 {
+			return fmt.Errorf("%s.connection.%s can not be negative", path, name)
+		}
+	}
+	return nil
+}
+
+// validateReplication check that the remote storage of the replication is accessed with the keys of secrets.
+func validateReplication(replication *MinIOReplication) error {
+	if replication == nil {
+		return nil
+	}
+	if replication.Endpoint == "" || replication.Bucket == "" {
+		return errors.New(".storage.inCluster.replication.endpoint and bucket are required")
+	}
+	if replication.AccessKeyRef == nil || replication.SecretKeyRef == nil {
+		return errors.New(".storage.inCluster.replication.accesskeyRef and secretkeyRef are required")
+	}
+	if err := validateCredential("accesskey", "", replication.AccessKeyRef, true); err != nil {
+		return err
+	}
+	if err := validateCredential("secretkey", "", replication.SecretKeyRef, true); err != nil {
+		return err
+	}
+	if replication.Interval != nil && replication.Interval.Duration <= 0 {
+		return errors.New(".storage.inCluster.replication.interval must be positive")
+	}
+	return nil
+}
+
+// validateMinIOPools check that every pool can build erasure sets, and that the pools share the volumes
+// which are applied to the whole minIO instance.
+func validateMinIOPools(pools []MinIOPool) error {
+	if isStandaloneMinIO(pools) {
+		return nil
+	}
+
+	for i, pool := range pools {
+		if pool.Servers < 1 || !hasErasureSetSize(int(pool.Servers)*getVolumesPerServer(pool)) {
+			return fmt.Errorf(".storage.inCluster.spec.pools[%d]: the drives of a distributed pool must be a multiple of 4 to 16", i)
+		}
+		if pool.VolumesPerServer != pools[0].VolumesPerServer ||
+			!reflect.DeepEqual(pool.VolumeClaimTemplate, pools[0].VolumeClaimTemplate) {
+			return fmt.Errorf(".storage.inCluster.spec.pools[%d]: volumesPerServer and volumeClaimTemplate must be the same for all pools", i)
+		}
+	}
+
+	return nil
+}
+
+// getVolumesPerServer returns the volumes per server of the pool, default is 1.
+func getVolumesPerServer(pool MinIOPool) int {
+	if pool.VolumesPerServer == 0 {
+		return 1
+	}
+	return pool.VolumesPerServer
+}
+
+// isStandaloneMinIO check whether minIO runs in standalone mode, with a single server.
+func isStandaloneMinIO(pools []MinIOPool) bool {
+	return len(pools) == 1 && pools[0].Servers == 1
+}
+
+// hasErasureSetSize check whether the drives can be divided into erasure sets of 4 to 16 drives.
+func hasErasureSetSize(drives int) bool {
+	for size := 16; size >= 4; size-- {
+		if drives%size == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateMinIOExpansion check that the existing pools of the in-cluster minIO are unchanged,
+// the capacity can only be expanded by appending pools, or by upgrading a standalone minIO to distributed mode.
+// The encryption can not be changed once enabled.
+func (r *HarborCluster) ValidateMinIOExpansion(old runtime.Object) error {
+	oldHarbor := old.(*HarborCluster)
+	if r.Spec.Storage == nil || r.Spec.Storage.InCluster == nil || r.Spec.Storage.InCluster.Spec == nil ||
+		oldHarbor.Spec.Storage == nil || oldHarbor.Spec.Storage.InCluster == nil || oldHarbor.Spec.Storage.InCluster.Spec == nil {
+		return nil
+	}
+
+	pools := r.Spec.Storage.InCluster.Spec.GetPools()
+	oldPools := oldHarbor.Spec.Storage.InCluster.Spec.GetPools()
+	if isStandaloneMinIO(oldPools) && !isStandaloneMinIO(pools) {
+		// the objects are migrated to a new distributed minIO instance.
+		migration := oldHarbor.Status.StorageMigration
+		if migration != nil && migration.Phase != StorageMigrationCompleted {
+			return errors.New("minIO can not be upgraded to distributed mode while a storage migration is in progress")
+		}
+	} else {
+		if len(pools) < len(oldPools) {
+			return errors.New("removing minIO pools is not supported")
+		}
+		for i := range oldPools {
+			if pools[i].Servers != oldPools[i].Servers {
+				return fmt.Errorf("changing servers of the existing minIO pool %d is not supported, append a new pool instead", i)
+			}
+			// the drives of the servers would not match the erasure sets of the pool anymore.
+			if getVolumesPerServer(pools[i]) != getVolumesPerServer(oldPools[i]) ||
+				!reflect.DeepEqual(pools[i].VolumeClaimTemplate, oldPools[i].VolumeClaimTemplate) {
+				return fmt.Errorf("changing volumesPerServer or volumeClaimTemplate of the existing minIO pool %d is not supported, append a new pool instead", i)
+			}
+		}
+	}
+
+	// the objects encrypted with the former KMS could not be decrypted anymore.
+	oldEncryption := oldHarbor.Spec.Storage.InCluster.Spec.Encryption
+	if oldEncryption != nil && !isSameKMS(r.Spec.Storage.InCluster.Spec.Encryption, oldEncryption) {
+		return errors.New("the encryption of minIO can not be changed or disabled once enabled")
+	}
+
+	return nil
+}
+
+// isSameKMS check that the encryption uses the same master key.
+func isSameKMS(encryption, oldEncryption *MinIOEncryption) bool {
+	return encryption != nil && reflect.DeepEqual(encryption.MasterKeySecret, oldEncryption.MasterKeySecret)
+}
+
+// validateCreateBucket check that the KMS key is only set for the aws:kms encryption.
+func validateCreateBucket(createBucket *CreateBucket) error {
+	if createBucket != nil && createBucket.KMSKeyID != "" && createBucket.Encryption != "aws:kms" {
+		return errors.New("createBucket.kmsKeyId requires the aws:kms encryption")
+	}
+	return nil
+}
+
+func validateCredential(name, plain string, ref *SecretKeyRef, required bool) error {
+	if plain != "" && ref != nil {
+		return fmt.Errorf("only one of %s and %sRef can be set", name, name)
+	}
+	if required && plain == "" && ref == nil {
+		return fmt.Errorf("%s or %sRef is required", name, name)
+	}
+	if ref != nil && (ref.Name == "" || ref.Key == "") {
+		return fmt.Errorf("%sRef.name and %sRef.key are required", name, name)
+	}
 	return nil
 }
@@ -0,0 +1,281 @@
+package v1
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestHasErasureSetSize(t *testing.T) {
+	tests := []struct {
+		drives int
+		want   bool
+	}{
+		{drives: 1, want: false},
+		{drives: 3, want: false},
+		{drives: 4, want: true},
+		{drives: 6, want: true},
+		{drives: 16, want: true},
+		{drives: 17, want: false},
+		{drives: 19, want: false},
+		{drives: 20, want: true},
+		{drives: 32, want: true},
+	}
+	for _, tt := range tests {
+		if got := hasErasureSetSize(tt.drives); got != tt.want {
+			t.Errorf("hasErasureSetSize(%d) = %v, want %v", tt.drives, got, tt.want)
+		}
+	}
+}
+
+func TestValidateMinIOPools(t *testing.T) {
+	claim := func(size string) corev1.PersistentVolumeClaim {
+		return corev1.PersistentVolumeClaim{
+			Spec: corev1.PersistentVolumeClaimSpec{
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		pools   []MinIOPool
+		wantErr bool
+	}{
+		{
+			name:  "standalone",
+			pools: []MinIOPool{{Servers: 1}},
+		},
+		{
+			name:  "distributed pool of 4 drives",
+			pools: []MinIOPool{{Servers: 4, VolumeClaimTemplate: claim("10Gi")}},
+		},
+		{
+			name:  "distributed pool of 2 servers with 2 volumes",
+			pools: []MinIOPool{{Servers: 2, VolumesPerServer: 2}},
+		},
+		{
+			name:    "distributed pool of 3 drives",
+			pools:   []MinIOPool{{Servers: 3}},
+			wantErr: true,
+		},
+		{
+			name:    "pool without server",
+			pools:   []MinIOPool{{Servers: 4}, {Servers: 0}},
+			wantErr: true,
+		},
+		{
+			name:  "appended pool",
+			pools: []MinIOPool{{Servers: 4, VolumeClaimTemplate: claim("10Gi")}, {Servers: 8, VolumeClaimTemplate: claim("10Gi")}},
+		},
+		{
+			name:    "appended pool with other volumes per server",
+			pools:   []MinIOPool{{Servers: 4}, {Servers: 2, VolumesPerServer: 2}},
+			wantErr: true,
+		},
+		{
+			name:    "appended pool with other volume claim template",
+			pools:   []MinIOPool{{Servers: 4, VolumeClaimTemplate: claim("10Gi")}, {Servers: 4, VolumeClaimTemplate: claim("20Gi")}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMinIOPools(tt.pools)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateMinIOPools() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMinIOExpansion(t *testing.T) {
+	claim := func(size string) corev1.PersistentVolumeClaim {
+		return corev1.PersistentVolumeClaim{
+			Spec: corev1.PersistentVolumeClaimSpec{
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+				},
+			},
+		}
+	}
+	cluster := func(pools ...MinIOPool) *HarborCluster {
+		return &HarborCluster{Spec: HarborClusterSpec{Storage: &Storage{
+			Kind:      "inCluster",
+			InCluster: &InCluster{Spec: &MinIOSpec{Pools: pools}},
+		}}}
+	}
+
+	tests := []struct {
+		name    string
+		old     *HarborCluster
+		new     *HarborCluster
+		wantErr bool
+	}{
+		{
+			name: "unchanged",
+			old:  cluster(MinIOPool{Servers: 4, VolumeClaimTemplate: claim("10Gi")}),
+			new:  cluster(MinIOPool{Servers: 4, VolumeClaimTemplate: claim("10Gi")}),
+		},
+		{
+			name: "appended pool",
+			old:  cluster(MinIOPool{Servers: 4, VolumeClaimTemplate: claim("10Gi")}),
+			new:  cluster(MinIOPool{Servers: 4, VolumeClaimTemplate: claim("10Gi")}, MinIOPool{Servers: 4, VolumeClaimTemplate: claim("10Gi")}),
+		},
+		{
+			name:    "removed pool",
+			old:     cluster(MinIOPool{Servers: 4}, MinIOPool{Servers: 4}),
+			new:     cluster(MinIOPool{Servers: 4}),
+			wantErr: true,
+		},
+		{
+			name:    "servers changed",
+			old:     cluster(MinIOPool{Servers: 4}),
+			new:     cluster(MinIOPool{Servers: 8}),
+			wantErr: true,
+		},
+		{
+			name: "default volumes per server set",
+			old:  cluster(MinIOPool{Servers: 4}),
+			new:  cluster(MinIOPool{Servers: 4, VolumesPerServer: 1}),
+		},
+		{
+			name:    "volumes per server changed",
+			old:     cluster(MinIOPool{Servers: 4}),
+			new:     cluster(MinIOPool{Servers: 4, VolumesPerServer: 2}),
+			wantErr: true,
+		},
+		{
+			name:    "volume claim template changed",
+			old:     cluster(MinIOPool{Servers: 4, VolumeClaimTemplate: claim("10Gi")}),
+			new:     cluster(MinIOPool{Servers: 4, VolumeClaimTemplate: claim("20Gi")}),
+			wantErr: true,
+		},
+		{
+			name: "standalone upgraded to distributed",
+			old:  cluster(MinIOPool{Servers: 1, VolumeClaimTemplate: claim("10Gi")}),
+			new:  cluster(MinIOPool{Servers: 4, VolumeClaimTemplate: claim("20Gi")}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.new.ValidateMinIOExpansion(tt.old)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateMinIOExpansion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRedisEphemeral(t *testing.T) {
+	spec := func(ephemeral bool) *RedisSpec {
+		return &RedisSpec{Server: &RedisServer{Ephemeral: ephemeral}}
+	}
+	cluster := func(kind string, shared *RedisSpec, dedicated ...DedicatedRedis) *HarborCluster {
+		return &HarborCluster{Spec: HarborClusterSpec{Redis: &Redis{Kind: kind, Spec: shared, Dedicated: dedicated}}}
+	}
+
+	tests := []struct {
+		name    string
+		old     *HarborCluster
+		new     *HarborCluster
+		wantErr bool
+	}{
+		{
+			name: "unchanged",
+			old:  cluster(InClusterComponent, spec(true)),
+			new:  cluster(InClusterComponent, spec(true)),
+		},
+		{
+			name:    "shared redis becomes persistent",
+			old:     cluster(InClusterComponent, spec(true)),
+			new:     cluster(InClusterComponent, &RedisSpec{}),
+			wantErr: true,
+		},
+		{
+			name: "external redis",
+			old:  cluster(ExternalComponent, spec(false)),
+			new:  cluster(ExternalComponent, spec(true)),
+		},
+		{
+			name:    "dedicated redis becomes ephemeral",
+			old:     cluster(InClusterComponent, nil, DedicatedRedis{Name: "cache", Kind: InClusterComponent, Spec: spec(false)}),
+			new:     cluster(InClusterComponent, nil, DedicatedRedis{Name: "cache", Kind: InClusterComponent, Spec: spec(true)}),
+			wantErr: true,
+		},
+		{
+			name: "renamed dedicated redis",
+			old:  cluster(InClusterComponent, nil, DedicatedRedis{Name: "cache", Kind: InClusterComponent, Spec: spec(false)}),
+			new:  cluster(InClusterComponent, nil, DedicatedRedis{Name: "caches", Kind: InClusterComponent, Spec: spec(true)}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.new.ValidateRedisEphemeral(tt.old)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRedisEphemeral() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateStorageRootDirectory(t *testing.T) {
+	s3 := func(rootDirectory string, createBucket *CreateBucket) *HarborCluster {
+		return &HarborCluster{Spec: HarborClusterSpec{Storage: &Storage{Kind: "s3", S3: &S3{RootDirectory: rootDirectory, CreateBucket: createBucket}}}}
+	}
+	oss := func(rootDirectory string, createBucket *CreateBucket) *HarborCluster {
+		return &HarborCluster{Spec: HarborClusterSpec{Storage: &Storage{Kind: "oss", Oss: &Oss{RootDirectory: rootDirectory, CreateBucket: createBucket}}}}
+	}
+	inCluster := &HarborCluster{Spec: HarborClusterSpec{Storage: &Storage{Kind: InClusterComponent}}}
+
+	tests := []struct {
+		name    string
+		old     *HarborCluster
+		new     *HarborCluster
+		wantErr bool
+	}{
+		{
+			name: "unchanged",
+			old:  s3("", &CreateBucket{}),
+			new:  s3("", &CreateBucket{Versioning: true}),
+		},
+		{
+			name:    "createBucket enabled without root directory",
+			old:     s3("", nil),
+			new:     s3("", &CreateBucket{}),
+			wantErr: true,
+		},
+		{
+			name:    "createBucket disabled without root directory",
+			old:     oss("", &CreateBucket{}),
+			new:     oss("", nil),
+			wantErr: true,
+		},
+		{
+			name: "createBucket enabled with root directory",
+			old:  s3("/harbor", nil),
+			new:  s3("/harbor", &CreateBucket{}),
+		},
+		{
+			name: "root directory set to the default one with createBucket",
+			old:  s3("", &CreateBucket{}),
+			new:  s3("/default/harbor", nil),
+		},
+		{
+			name: "migrated from the in-cluster storage",
+			old:  inCluster,
+			new:  s3("", &CreateBucket{}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.new.ValidateStorageRootDirectory(tt.old)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateStorageRootDirectory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
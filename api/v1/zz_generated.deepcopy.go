@@ -20,12 +20,19 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Azure) DeepCopyInto(out *Azure) {
 	*out = *in
+	if in.AccountKeyRef != nil {
+		in, out := &in.AccountKeyRef, &out.AccountKeyRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Azure.
@@ -38,9 +45,44 @@ func (in *Azure) DeepCopy() *Azure {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BucketMigrationStatus) DeepCopyInto(out *BucketMigrationStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BucketMigrationStatus.
+func (in *BucketMigrationStatus) DeepCopy() *BucketMigrationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BucketMigrationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BucketReplicationStatus) DeepCopyInto(out *BucketReplicationStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BucketReplicationStatus.
+func (in *BucketReplicationStatus) DeepCopy() *BucketReplicationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BucketReplicationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ChartMuseum) DeepCopyInto(out *ChartMuseum) {
 	*out = *in
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(ChartMuseumStorage)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChartMuseum.
@@ -53,6 +95,36 @@ func (in *ChartMuseum) DeepCopy() *ChartMuseum {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChartMuseumStorage) DeepCopyInto(out *ChartMuseumStorage) {
+	*out = *in
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(S3)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Oss != nil {
+		in, out := &in.Oss, &out.Oss
+		*out = new(Oss)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Azure != nil {
+		in, out := &in.Azure, &out.Azure
+		*out = new(Azure)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChartMuseumStorage.
+func (in *ChartMuseumStorage) DeepCopy() *ChartMuseumStorage {
+	if in == nil {
+		return nil
+	}
+	out := new(ChartMuseumStorage)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Clair) DeepCopyInto(out *Clair) {
 	*out = *in
@@ -73,6 +145,53 @@ func (in *Clair) DeepCopy() *Clair {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CreateBucket) DeepCopyInto(out *CreateBucket) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CreateBucket.
+func (in *CreateBucket) DeepCopy() *CreateBucket {
+	if in == nil {
+		return nil
+	}
+	out := new(CreateBucket)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CredentialRotation) DeepCopyInto(out *CredentialRotation) {
+	*out = *in
+	out.Interval = in.Interval
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CredentialRotation.
+func (in *CredentialRotation) DeepCopy() *CredentialRotation {
+	if in == nil {
+		return nil
+	}
+	out := new(CredentialRotation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CredentialRotationStatus) DeepCopyInto(out *CredentialRotationStatus) {
+	*out = *in
+	in.LastRotationTime.DeepCopyInto(&out.LastRotationTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CredentialRotationStatus.
+func (in *CredentialRotationStatus) DeepCopy() *CredentialRotationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CredentialRotationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Database) DeepCopyInto(out *Database) {
 	*out = *in
@@ -93,9 +212,39 @@ func (in *Database) DeepCopy() *Database {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DedicatedRedis) DeepCopyInto(out *DedicatedRedis) {
+	*out = *in
+	if in.Components != nil {
+		in, out := &in.Components, &out.Components
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Spec != nil {
+		in, out := &in.Spec, &out.Spec
+		*out = new(RedisSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DedicatedRedis.
+func (in *DedicatedRedis) DeepCopy() *DedicatedRedis {
+	if in == nil {
+		return nil
+	}
+	out := new(DedicatedRedis)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Gcs) DeepCopyInto(out *Gcs) {
 	*out = *in
+	if in.EncodedKeyRef != nil {
+		in, out := &in.EncodedKeyRef, &out.EncodedKeyRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Gcs.
@@ -215,7 +364,7 @@ func (in *HarborClusterSpec) DeepCopyInto(out *HarborClusterSpec) {
 	if in.ChartMuseum != nil {
 		in, out := &in.ChartMuseum, &out.ChartMuseum
 		*out = new(ChartMuseum)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Notary != nil {
 		in, out := &in.Notary, &out.Notary
@@ -237,6 +386,11 @@ func (in *HarborClusterSpec) DeepCopyInto(out *HarborClusterSpec) {
 		*out = new(Storage)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.CredentialRotation != nil {
+		in, out := &in.CredentialRotation, &out.CredentialRotation
+		*out = new(CredentialRotation)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarborClusterSpec.
@@ -259,6 +413,38 @@ func (in *HarborClusterStatus) DeepCopyInto(out *HarborClusterStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.CredentialRotation != nil {
+		in, out := &in.CredentialRotation, &out.CredentialRotation
+		*out = new(CredentialRotationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StorageMigration != nil {
+		in, out := &in.StorageMigration, &out.StorageMigration
+		*out = new(StorageMigrationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StorageUsage != nil {
+		in, out := &in.StorageUsage, &out.StorageUsage
+		*out = new(StorageUsageStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StorageUsageCollection != nil {
+		in, out := &in.StorageUsageCollection, &out.StorageUsageCollection
+		*out = new(StorageUsageCollectionStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StorageReplication != nil {
+		in, out := &in.StorageReplication, &out.StorageReplication
+		*out = new(StorageReplicationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StorageProbes != nil {
+		in, out := &in.StorageProbes, &out.StorageProbes
+		*out = make([]StorageProbeStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarborClusterStatus.
@@ -309,6 +495,11 @@ func (in *InCluster) DeepCopyInto(out *InCluster) {
 		*out = new(MinIOSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Replication != nil {
+		in, out := &in.Replication, &out.Replication
+		*out = new(MinIOReplication)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InCluster.
@@ -336,11 +527,89 @@ func (in *JobService) DeepCopy() *JobService {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MinIOEncryption) DeepCopyInto(out *MinIOEncryption) {
+	*out = *in
+	if in.MasterKeySecret != nil {
+		in, out := &in.MasterKeySecret, &out.MasterKeySecret
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MinIOEncryption.
+func (in *MinIOEncryption) DeepCopy() *MinIOEncryption {
+	if in == nil {
+		return nil
+	}
+	out := new(MinIOEncryption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MinIOPool) DeepCopyInto(out *MinIOPool) {
+	*out = *in
+	in.VolumeClaimTemplate.DeepCopyInto(&out.VolumeClaimTemplate)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MinIOPool.
+func (in *MinIOPool) DeepCopy() *MinIOPool {
+	if in == nil {
+		return nil
+	}
+	out := new(MinIOPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MinIOReplication) DeepCopyInto(out *MinIOReplication) {
+	*out = *in
+	if in.AccessKeyRef != nil {
+		in, out := &in.AccessKeyRef, &out.AccessKeyRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MinIOReplication.
+func (in *MinIOReplication) DeepCopy() *MinIOReplication {
+	if in == nil {
+		return nil
+	}
+	out := new(MinIOReplication)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MinIOSpec) DeepCopyInto(out *MinIOSpec) {
 	*out = *in
+	if in.Pools != nil {
+		in, out := &in.Pools, &out.Pools
+		*out = make([]MinIOPool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	in.VolumeClaimTemplate.DeepCopyInto(&out.VolumeClaimTemplate)
 	in.Resources.DeepCopyInto(&out.Resources)
+	if in.Encryption != nil {
+		in, out := &in.Encryption, &out.Encryption
+		*out = new(MinIOEncryption)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MinIOSpec.
@@ -371,6 +640,16 @@ func (in *Notary) DeepCopy() *Notary {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Oss) DeepCopyInto(out *Oss) {
 	*out = *in
+	if in.AccessKeySecretRef != nil {
+		in, out := &in.AccessKeySecretRef, &out.AccessKeySecretRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+	if in.CreateBucket != nil {
+		in, out := &in.CreateBucket, &out.CreateBucket
+		*out = new(CreateBucket)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Oss.
@@ -407,6 +686,13 @@ func (in *Redis) DeepCopyInto(out *Redis) {
 		*out = new(RedisSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Dedicated != nil {
+		in, out := &in.Dedicated, &out.Dedicated
+		*out = make([]DedicatedRedis, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Redis.
@@ -419,10 +705,150 @@ func (in *Redis) DeepCopy() *Redis {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RedisComponentCache) DeepCopyInto(out *RedisComponentCache) {
+	*out = *in
+	if in.Database != nil {
+		in, out := &in.Database, &out.Database
+		*out = new(int)
+		**out = **in
+	}
+	if in.KeyPatterns != nil {
+		in, out := &in.KeyPatterns, &out.KeyPatterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RedisComponentCache.
+func (in *RedisComponentCache) DeepCopy() *RedisComponentCache {
+	if in == nil {
+		return nil
+	}
+	out := new(RedisComponentCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RedisComponents) DeepCopyInto(out *RedisComponents) {
+	*out = *in
+	if in.ChartMuseum != nil {
+		in, out := &in.ChartMuseum, &out.ChartMuseum
+		*out = new(RedisComponentCache)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Clair != nil {
+		in, out := &in.Clair, &out.Clair
+		*out = new(RedisComponentCache)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JobService != nil {
+		in, out := &in.JobService, &out.JobService
+		*out = new(RedisComponentCache)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Registry != nil {
+		in, out := &in.Registry, &out.Registry
+		*out = new(RedisComponentCache)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RedisComponents.
+func (in *RedisComponents) DeepCopy() *RedisComponents {
+	if in == nil {
+		return nil
+	}
+	out := new(RedisComponents)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RedisConnectionProfile) DeepCopyInto(out *RedisConnectionProfile) {
+	*out = *in
+	if in.DialTimeout != nil {
+		in, out := &in.DialTimeout, &out.DialTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ReadTimeout != nil {
+		in, out := &in.ReadTimeout, &out.ReadTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.WriteTimeout != nil {
+		in, out := &in.WriteTimeout, &out.WriteTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.PoolTimeout != nil {
+		in, out := &in.PoolTimeout, &out.PoolTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.IdleTimeout != nil {
+		in, out := &in.IdleTimeout, &out.IdleTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RedisConnectionProfile.
+func (in *RedisConnectionProfile) DeepCopy() *RedisConnectionProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(RedisConnectionProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RedisPodSettings) DeepCopyInto(out *RedisPodSettings) {
+	*out = *in
+	if in.CustomConfig != nil {
+		in, out := &in.CustomConfig, &out.CustomConfig
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(corev1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RedisPodSettings.
+func (in *RedisPodSettings) DeepCopy() *RedisPodSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(RedisPodSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RedisServer) DeepCopyInto(out *RedisServer) {
 	*out = *in
 	in.Resources.DeepCopyInto(&out.Resources)
+	in.RedisPodSettings.DeepCopyInto(&out.RedisPodSettings)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RedisServer.
@@ -446,13 +872,23 @@ func (in *RedisSpec) DeepCopyInto(out *RedisSpec) {
 	if in.Sentinel != nil {
 		in, out := &in.Sentinel, &out.Sentinel
 		*out = new(Sentinel)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Hosts != nil {
 		in, out := &in.Hosts, &out.Hosts
 		*out = make([]Hosts, len(*in))
 		copy(*out, *in)
 	}
+	if in.Connection != nil {
+		in, out := &in.Connection, &out.Connection
+		*out = new(RedisConnectionProfile)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Components != nil {
+		in, out := &in.Components, &out.Components
+		*out = new(RedisComponents)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RedisSpec.
@@ -468,6 +904,21 @@ func (in *RedisSpec) DeepCopy() *RedisSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *S3) DeepCopyInto(out *S3) {
 	*out = *in
+	if in.AccessKeyRef != nil {
+		in, out := &in.AccessKeyRef, &out.AccessKeyRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+	if in.CreateBucket != nil {
+		in, out := &in.CreateBucket, &out.CreateBucket
+		*out = new(CreateBucket)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3.
@@ -480,9 +931,26 @@ func (in *S3) DeepCopy() *S3 {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeyRef) DeepCopyInto(out *SecretKeyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeyRef.
+func (in *SecretKeyRef) DeepCopy() *SecretKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Sentinel) DeepCopyInto(out *Sentinel) {
 	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+	in.RedisPodSettings.DeepCopyInto(&out.RedisPodSettings)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Sentinel.
@@ -506,26 +974,31 @@ func (in *Storage) DeepCopyInto(out *Storage) {
 	if in.Azure != nil {
 		in, out := &in.Azure, &out.Azure
 		*out = new(Azure)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Gcs != nil {
 		in, out := &in.Gcs, &out.Gcs
 		*out = new(Gcs)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.S3 != nil {
 		in, out := &in.S3, &out.S3
 		*out = new(S3)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Swift != nil {
 		in, out := &in.Swift, &out.Swift
 		*out = new(Swift)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Oss != nil {
 		in, out := &in.Oss, &out.Oss
 		*out = new(Oss)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UsageReportInterval != nil {
+		in, out := &in.UsageReportInterval, &out.UsageReportInterval
+		*out = new(metav1.Duration)
 		**out = **in
 	}
 }
@@ -540,9 +1013,137 @@ func (in *Storage) DeepCopy() *Storage {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageMigrationStatus) DeepCopyInto(out *StorageMigrationStatus) {
+	*out = *in
+	if in.PreviousReadOnly != nil {
+		in, out := &in.PreviousReadOnly, &out.PreviousReadOnly
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Buckets != nil {
+		in, out := &in.Buckets, &out.Buckets
+		*out = make([]BucketMigrationStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageMigrationStatus.
+func (in *StorageMigrationStatus) DeepCopy() *StorageMigrationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageMigrationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageProbeStatus) DeepCopyInto(out *StorageProbeStatus) {
+	*out = *in
+	in.LastProbeTime.DeepCopyInto(&out.LastProbeTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageProbeStatus.
+func (in *StorageProbeStatus) DeepCopy() *StorageProbeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageProbeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageReplicationStatus) DeepCopyInto(out *StorageReplicationStatus) {
+	*out = *in
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSyncStartTime != nil {
+		in, out := &in.LastSyncStartTime, &out.LastSyncStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Lag != nil {
+		in, out := &in.Lag, &out.Lag
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.CurrentSyncStartTime != nil {
+		in, out := &in.CurrentSyncStartTime, &out.CurrentSyncStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Buckets != nil {
+		in, out := &in.Buckets, &out.Buckets
+		*out = make([]BucketReplicationStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageReplicationStatus.
+func (in *StorageReplicationStatus) DeepCopy() *StorageReplicationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageReplicationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageUsageCollectionStatus) DeepCopyInto(out *StorageUsageCollectionStatus) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageUsageCollectionStatus.
+func (in *StorageUsageCollectionStatus) DeepCopy() *StorageUsageCollectionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageUsageCollectionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageUsageStatus) DeepCopyInto(out *StorageUsageStatus) {
+	*out = *in
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageUsageStatus.
+func (in *StorageUsageStatus) DeepCopy() *StorageUsageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageUsageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Swift) DeepCopyInto(out *Swift) {
 	*out = *in
+	if in.PasswordRef != nil {
+		in, out := &in.PasswordRef, &out.PasswordRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Swift.
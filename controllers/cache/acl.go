@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// redisACLUserPrefix prefixes the ACL users of the harbor components, e.g. "harbor-jobservice".
+	redisACLUserPrefix = "harbor-"
+
+	// ACLUsersCheckInterval is the delay between the readiness checks while the ACL users are enabled,
+	// so that a restarted or failed over redis server gets the ACL users again.
+	ACLUsersCheckInterval = 30 * time.Second
+)
+
+// GetACLUsersRequeue returns the delay until the ACL users are applied again,
+// it returns false if no inCluster redis has the ACL users enabled.
+func GetACLUsersRequeue(harborCluster *goharborv1.HarborCluster) (time.Duration, bool) {
+	redis := harborCluster.Spec.Redis
+	if redis == nil {
+		return 0, false
+	}
+
+	if redis.Kind == goharborv1.InClusterComponent && redis.Spec != nil && redis.Spec.ACLUsers && len(redis.GetSharedComponents()) > 0 {
+		return ACLUsersCheckInterval, true
+	}
+	for _, dedicated := range redis.Dedicated {
+		if dedicated.Kind == goharborv1.InClusterComponent && dedicated.Spec != nil && dedicated.Spec.ACLUsers {
+			return ACLUsersCheckInterval, true
+		}
+	}
+	return 0, false
+}
+
+// getACLUsername returns the ACL user of the harbor component.
+func getACLUsername(component string) string {
+	return redisACLUserPrefix + strings.ToLower(component)
+}
+
+// getACLPasswordKey returns the key of the password of the harbor component in the redis password secret.
+func getACLPasswordKey(component string) string {
+	return component + "Password"
+}
+
+// ensureACLPasswords returns the password of the ACL user of every component,
+// the missing passwords are generated and kept in the redis password secret.
+func (redis *RedisReconciler) ensureACLPasswords() (map[string]string, error) {
+	secret := &corev1.Secret{}
+	err := redis.Client.Get(types.NamespacedName{Name: redis.name, Namespace: redis.HarborCluster.Namespace}, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	passwords := make(map[string]string)
+	missing := make(map[string]string)
+	for _, component := range redis.components {
+		key := getACLPasswordKey(component)
+		password := string(secret.Data[key])
+		if password == "" {
+			password = RandomString(redisPasswordLength, "a")
+			missing[key] = password
+		}
+		passwords[component] = password
+	}
+
+	if len(missing) == 0 {
+		return passwords, nil
+	}
+
+	redis.Log.Info("Creating Redis ACL passwords", "namespace", redis.HarborCluster.Namespace, "name", redis.name)
+	secret.StringData = missing
+	return passwords, redis.Client.Update(secret)
+}
+
+// applyACLUsers sets the ACL user of every component on every redis server, so that they are kept by a failover.
+// The users are not in the redis config, a restarted redis server gets them again from the next readiness check,
+// which is requeued every ACLUsersCheckInterval.
+func (redis *RedisReconciler) applyACLUsers(passwords map[string]string) error {
+	_, redisPodList, err := redis.GetStatefulSetPods()
+	if err != nil {
+		return err
+	}
+	_, redisPods := redis.GetPodsStatus(redisPodList.Items)
+
+	for _, pod := range redisPods {
+		client := BuildRedisClient([]string{pod.Status.PodIP}, RedisServerPort, "", redis.RedisConnect.Password, 0, nil, redis.RedisConnect.Profile)
+		for _, component := range redis.components {
+			err = client.Do(redis.genACLSetUser(component, passwords[component])...).Err()
+			if err != nil {
+				break
+			}
+		}
+		client.Close()
+		if err != nil {
+			return fmt.Errorf("set ACL users of redis %s: %w", pod.Name, err)
+		}
+	}
+	return nil
+}
+
+// genACLSetUser returns the command replacing the password and the key patterns of the ACL user of the component,
+// the user can run every command but the administrative ones. The user is not reset, which would revoke
+// the pub/sub channels used by the jobService since redis 6.2.
+func (redis *RedisReconciler) genACLSetUser(component, password string) []interface{} {
+	hash := sha256.Sum256([]byte(password))
+
+	args := []interface{}{"ACL", "SETUSER", getACLUsername(component), "resetpass", "resetkeys", "on", "#" + hex.EncodeToString(hash[:])}
+	for _, pattern := range redis.spec.GetComponentKeyPatterns(component) {
+		args = append(args, "~"+pattern)
+	}
+	return append(args, "+@all", "-@admin")
+}
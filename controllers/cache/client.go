@@ -1,7 +1,11 @@
 package cache
 
 import (
+	"crypto/tls"
+	"errors"
+	"fmt"
 	rediscli "github.com/go-redis/redis"
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
 	"strings"
 	"time"
 )
@@ -24,42 +28,87 @@ const (
 )
 
 type RedisConnect struct {
-	Schema    string
+	Schema string
+	// Endpoints are the hosts of the redis servers or sentinels.
 	Endpoints []string
 	Port      string
+	// Username is the redis 6 ACL user, the password authenticates the default user if not set.
+	Username  string
 	Password  string
 	GroupName string
+	// SentinelAddrs are the "host:port" of every sentinel, which are checked to agree on the master.
+	SentinelAddrs []string
+	// SentinelPassword authenticates to the sentinels, which are not secured if not set.
+	SentinelPassword string
+	// TLSConfig is set when the redis connections are negotiated with TLS.
+	TLSConfig *tls.Config
+	// Profile is the connection pool, timeouts and retries of the clients, the defaults are used if nil.
+	Profile *goharborv1.RedisConnectionProfile
 }
 
 // NewRedisPool returns redis sentinel client
 func (c *RedisConnect) NewRedisPool() *rediscli.Client {
 
-	return BuildRedisPool(c.Endpoints, c.Port, c.Password, c.GroupName, 0)
+	return BuildRedisPool(c.Endpoints, c.Port, c.Username, c.Password, c.GroupName, 0, c.TLSConfig, c.Profile)
 }
 
 // NewRedisClient returns redis client
 func (c *RedisConnect) NewRedisClient() *rediscli.Client {
 
-	return BuildRedisClient(c.Endpoints, c.Port, c.Password, 0)
+	return BuildRedisClient(c.Endpoints, c.Port, c.Username, c.Password, 0, c.TLSConfig, c.Profile)
+}
+
+// NewRedisMasterClient returns redis client of the master known by the secured sentinels.
+// The sentinel client of go-redis can not authenticate to the sentinels, so the master is resolved once,
+// and a failover is followed by the next reconcile.
+func (c *RedisConnect) NewRedisMasterClient() (*rediscli.Client, error) {
+	var lastErr error
+	for _, addr := range c.SentinelAddrs {
+		sentinel := newSentinelClient(addr, c.SentinelPassword)
+		result, err := sentinel.Do("SENTINEL", "get-master-addr-by-name", c.GroupName).Result()
+		sentinel.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		addrs, ok := result.([]interface{})
+		if !ok || len(addrs) != 2 {
+			lastErr = fmt.Errorf("unexpected master address %v", result)
+			continue
+		}
+		host, port := fmt.Sprint(addrs[0]), fmt.Sprint(addrs[1])
+		return BuildRedisClient([]string{host}, port, c.Username, c.Password, 0, c.TLSConfig, c.Profile), nil
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("no sentinel to get the master from")
+	}
+	return nil, lastErr
 }
 
 // BuildRedisPool returns redis connection pool client
-func BuildRedisPool(redisSentinelIP []string, redisSentinelPort, redisSentinelPassword, redisGroupName string, redisIndex int) *rediscli.Client {
+func BuildRedisPool(redisSentinelIP []string, redisSentinelPort, redisUsername, redisSentinelPassword, redisGroupName string, redisIndex int, tlsConfig *tls.Config, profile *goharborv1.RedisConnectionProfile) *rediscli.Client {
 
 	sentinelsInfo := GenHostInfo(redisSentinelIP, redisSentinelPort)
+	p := getConnectionProfile(profile)
 
 	options := &rediscli.FailoverOptions{
-		MasterName:         redisGroupName,
-		SentinelAddrs:      sentinelsInfo,
-		Password:           redisSentinelPassword,
-		DB:                 redisIndex,
-		PoolSize:           100,
-		DialTimeout:        10 * time.Second,
-		ReadTimeout:        30 * time.Second,
-		WriteTimeout:       30 * time.Second,
-		PoolTimeout:        30 * time.Second,
-		IdleTimeout:        time.Millisecond,
-		IdleCheckFrequency: time.Millisecond,
+		MasterName:    redisGroupName,
+		SentinelAddrs: sentinelsInfo,
+		Password:      redisSentinelPassword,
+		DB:            redisIndex,
+		PoolSize:      p.poolSize,
+		MaxRetries:    p.maxRetries,
+		DialTimeout:   p.dialTimeout,
+		ReadTimeout:   p.readTimeout,
+		WriteTimeout:  p.writeTimeout,
+		PoolTimeout:   p.poolTimeout,
+		IdleTimeout:   p.idleTimeout,
+		TLSConfig:     tlsConfig,
+	}
+	if redisUsername != "" {
+		options.Password = ""
+		options.OnConnect = authACLUser(redisUsername, redisSentinelPassword)
 	}
 
 	client := rediscli.NewFailoverClient(options)
@@ -69,12 +118,25 @@ func BuildRedisPool(redisSentinelIP []string, redisSentinelPort, redisSentinelPa
 }
 
 // BuildRedisClient returns redis connection client
-func BuildRedisClient(host []string, port, password string, index int) *rediscli.Client {
+func BuildRedisClient(host []string, port, username, password string, index int, tlsConfig *tls.Config, profile *goharborv1.RedisConnectionProfile) *rediscli.Client {
 	hostInfo := GenHostInfo(host, port)
+	p := getConnectionProfile(profile)
 	options := &rediscli.Options{
-		Addr:     strings.Join(hostInfo[:], ","),
-		Password: password,
-		DB:       index,
+		Addr:         strings.Join(hostInfo[:], ","),
+		Password:     password,
+		DB:           index,
+		PoolSize:     p.poolSize,
+		MaxRetries:   p.maxRetries,
+		DialTimeout:  p.dialTimeout,
+		ReadTimeout:  p.readTimeout,
+		WriteTimeout: p.writeTimeout,
+		PoolTimeout:  p.poolTimeout,
+		IdleTimeout:  p.idleTimeout,
+		TLSConfig:    tlsConfig,
+	}
+	if username != "" {
+		options.Password = ""
+		options.OnConnect = authACLUser(username, password)
 	}
 	client := rediscli.NewClient(options)
 
@@ -82,6 +144,20 @@ func BuildRedisClient(host []string, port, password string, index int) *rediscli
 
 }
 
+// authACLUser returns the hook authenticating the new connections as the redis 6 ACL user.
+// go-redis only sends the AUTH of the default user, so the password must not be set in the options,
+// and the database must be 0 as it is selected before the hook.
+func authACLUser(username, password string) func(*rediscli.Conn) error {
+	return func(conn *rediscli.Conn) error {
+		return conn.Do("AUTH", username, password).Err()
+	}
+}
+
+// newSentinelClient returns client of the sentinel, authenticated with the sentinel password if set.
+func newSentinelClient(addr, password string) *rediscli.Client {
+	return rediscli.NewClient(&rediscli.Options{Addr: addr, Password: password, DialTimeout: 5 * time.Second})
+}
+
 // GenHostInfo splice host and port
 func GenHostInfo(endpoint []string, port string) []string {
 	var hostInfo []string
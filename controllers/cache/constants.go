@@ -16,6 +16,15 @@ const (
 	ManualFailoverRedisError          = "Manual failover redis error"
 	UpdateRedisCrError                = "Update redis cr error"
 	DefaultUnstructuredConverterError = "Default unstructured converter error"
+	RedisMasterNotElectedError        = "Redis master not elected"
+	RedisSentinelQuorumError          = "Redis sentinel quorum error"
+	RedisReplicasNotInSyncError       = "Redis replicas not in sync"
+	RedisMasterNotWritableError       = "Redis master not writable"
+	CreateRedisShutdownConfigMapError = "Create redis shutdown configmap error"
+	RedisFailingOver                  = "Redis failing over"
+	DeleteRedisCrError                = "Delete redis cr error"
+	CreateRedisACLPasswordError       = "Create redis ACL password error"
+	ApplyRedisACLUsersError           = "Apply redis ACL users error"
 )
 
 const (
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// getInstances returns a reconciler of every redis serving harbor components: the redis of .redis.spec,
+// named after the harbor cluster, unless every component uses a dedicated redis, and the dedicated redis,
+// named after the harbor cluster and their name.
+func (redis *RedisReconciler) getInstances() []*RedisReconciler {
+	var instances []*RedisReconciler
+
+	if components := redis.HarborCluster.Spec.Redis.GetSharedComponents(); len(components) > 0 {
+		instances = append(instances, redis.forInstance(redis.HarborCluster.Name,
+			redis.HarborCluster.Spec.Redis.Kind, redis.HarborCluster.Spec.Redis.Spec, components))
+	}
+
+	for _, dedicated := range redis.HarborCluster.Spec.Redis.Dedicated {
+		instances = append(instances, redis.forInstance(redis.HarborCluster.Name+"-"+dedicated.Name,
+			dedicated.Kind, dedicated.Spec, dedicated.Components))
+	}
+
+	return instances
+}
+
+// forInstance returns a copy of the reconciler which manages the given redis.
+func (redis *RedisReconciler) forInstance(name, kind string, spec *goharborv1.RedisSpec, components []string) *RedisReconciler {
+	reconciler := *redis
+	reconciler.name = name
+	reconciler.kind = kind
+	reconciler.spec = spec
+	reconciler.components = components
+	reconciler.ExpectCR = nil
+	reconciler.ActualCR = nil
+	reconciler.RedisConnect = nil
+	return &reconciler
+}
+
+// deleteStaleInstances deletes the inCluster redis of the harbor cluster which are not served anymore, after a
+// dedicated redis is removed or renamed, or switched to an external redis. The persistent volume claims are owned
+// by the redis failover, the password secret and the shutdown config map are deleted along with it.
+func (redis *RedisReconciler) deleteStaleInstances() error {
+	names := make(map[string]bool)
+	for _, instance := range redis.getInstances() {
+		if instance.kind == goharborv1.InClusterComponent {
+			names[instance.name] = true
+		}
+	}
+
+	crdClient := redis.DClient.WithResource(redisFailoversGVR).WithNamespace(redis.HarborCluster.Namespace)
+	failovers, err := crdClient.List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{AppLabel: redis.HarborCluster.Name}).String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range failovers.Items {
+		failover := &failovers.Items[i]
+		if names[failover.GetName()] || !metav1.IsControlledBy(failover, redis.HarborCluster) {
+			continue
+		}
+
+		redis.Log.Info("Deleting stale Redis", "namespace", failover.GetNamespace(), "name", failover.GetName())
+		err := crdClient.Delete(failover.GetName(), metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+
+		stale := redis.forInstance(failover.GetName(), goharborv1.InClusterComponent, nil, nil)
+		if err := redis.deleteOwned(stale.name, &corev1.Secret{}); err != nil {
+			return err
+		}
+		if err := redis.deleteOwned(stale.getShutdownConfigMapName(), &corev1.ConfigMap{}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteOwned deletes the object of the harbor cluster namespace if it is controlled by the harbor cluster,
+// so that the secrets provided by the user are never deleted.
+func (redis *RedisReconciler) deleteOwned(name string, obj runtime.Object) error {
+	err := redis.Client.Get(types.NamespacedName{Name: name, Namespace: redis.HarborCluster.Namespace}, obj)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	meta, ok := obj.(metav1.Object)
+	if !ok || !metav1.IsControlledBy(meta, redis.HarborCluster) {
+		return nil
+	}
+	err = redis.Client.Delete(obj)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
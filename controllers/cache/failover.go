@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	rediscli "github.com/go-redis/redis"
+	corev1 "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	redisShutdownSuffix    = "redis-shutdown"
+	redisShutdownScriptKey = "shutdown.sh"
+
+	// redisShutdownFailoverTimeout is the number of seconds the stopping master waits for the new master,
+	// within the default termination grace period of the redis pods.
+	redisShutdownFailoverTimeout = 20
+)
+
+// redisShutdownScript is run before a redis pod is stopped. The master asks the sentinels to fail over, and waits
+// for a new master before stopping, instead of leaving the sentinels to notice it is down.
+const redisShutdownScript = `sentinel=%[1]s
+get_master() {
+  redis-cli -h $sentinel -p %[2]s --csv SENTINEL get-master-addr-by-name %[3]s | tr ',' ' ' | tr -d '"' | cut -d' ' -f1
+}
+if [ "$(get_master)" = "$(hostname -i)" ]; then
+  redis-cli -h $sentinel -p %[2]s SENTINEL failover %[3]s
+  for i in $(seq 1 %[4]d); do
+    [ "$(get_master)" != "$(hostname -i)" ] && break
+    sleep 1
+  done
+fi
+redis-cli -a "${REDIS_PASSWORD}" SAVE
+`
+
+func (redis *RedisReconciler) getShutdownConfigMapName() string {
+	return redis.name + "-" + redisShutdownSuffix
+}
+
+// generateRedisShutdownConfigMap returns the shutdown script of the redis pods, replacing the script of the redis
+// operator which only finds the sentinels of a redis named "redis".
+func (redis *RedisReconciler) generateRedisShutdownConfigMap() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      redis.getShutdownConfigMapName(),
+			Namespace: redis.HarborCluster.Namespace,
+			Labels:    redis.Labels,
+		},
+		Data: map[string]string{
+			redisShutdownScriptKey: fmt.Sprintf(redisShutdownScript,
+				generateName(SentinelType, redis.name), RedisSentinelConnPort, RedisSentinelConnGroup, redisShutdownFailoverTimeout),
+		},
+	}
+}
+
+// ensureShutdownConfigMap creates or updates the shutdown script of the redis pods.
+func (redis *RedisReconciler) ensureShutdownConfigMap() error {
+	expected := redis.generateRedisShutdownConfigMap()
+	if err := controllerutil.SetControllerReference(redis.HarborCluster, expected, redis.Scheme); err != nil {
+		return err
+	}
+
+	configMap := &corev1.ConfigMap{}
+	err := redis.Client.Get(types.NamespacedName{Name: expected.Name, Namespace: expected.Namespace}, configMap)
+	if kerr.IsNotFound(err) {
+		redis.Log.Info("Creating Redis Shutdown ConfigMap", "namespace", expected.Namespace, "name", expected.Name)
+		return redis.Client.Create(expected)
+	} else if err != nil {
+		return err
+	}
+
+	if configMap.Data[redisShutdownScriptKey] == expected.Data[redisShutdownScriptKey] {
+		return nil
+	}
+	configMap.Data = expected.Data
+	return redis.Client.Update(configMap)
+}
+
+// failoverFromRemovedPods moves the master away from the redis pods removed by scaling down to the given replicas,
+// and returns whether the master is kept. The removed pods are never promoted, since their replica priority is 0.
+func (redis *RedisReconciler) failoverFromRemovedPods(replicas int32) (bool, error) {
+	_, redisPodList, err := redis.GetStatefulSetPods()
+	if err != nil {
+		return false, err
+	}
+
+	master, err := redis.getRedisMaster()
+	if err != nil {
+		return false, err
+	}
+
+	removedPods, isMasterRemoved := getRemovedPods(redisPodList.Items, replicas, master)
+	if !isMasterRemoved {
+		return true, nil
+	}
+
+	password, err := redis.GetRedisPassword(redis.name)
+	if err != nil {
+		return false, err
+	}
+	for _, pod := range removedPods {
+		if err := configSetRedis(pod.Status.PodIP, "slave-priority", "0", password); err != nil {
+			return false, fmt.Errorf("set slave-priority of redis %s: %w", pod.Name, err)
+		}
+	}
+
+	redis.Log.Info("Failing over the redis master before scaling down",
+		"namespace", redis.HarborCluster.Namespace, "name", redis.name, "master", master)
+	return false, redis.failoverSentinels()
+}
+
+// getRemovedPods returns the redis pods removed by scaling down to the given replicas, and whether the master is one of them.
+func getRemovedPods(pods []corev1.Pod, replicas int32, master string) ([]corev1.Pod, bool) {
+	var removedPods []corev1.Pod
+	isMasterRemoved := false
+	for _, pod := range pods {
+		if getPodOrdinal(pod.Name) < int(replicas) {
+			continue
+		}
+		removedPods = append(removedPods, pod)
+		if pod.Status.PodIP == master {
+			isMasterRemoved = true
+		}
+	}
+	return removedPods, isMasterRemoved
+}
+
+// getRedisMaster returns the ip of the master known by the first reachable sentinel.
+func (redis *RedisReconciler) getRedisMaster() (string, error) {
+	_, sentinelPodList, err := redis.GetDeploymentPods()
+	if err != nil {
+		return "", err
+	}
+
+	err = fmt.Errorf("no sentinel of %s is running", redis.name)
+	for _, pod := range sentinelPodList.Items {
+		var master string
+		master, _, err = getSentinelMaster(pod.Status.PodIP+":"+RedisSentinelConnPort, RedisSentinelConnGroup, "")
+		if err == nil {
+			host, _, err := net.SplitHostPort(master)
+			return host, err
+		}
+	}
+	return "", err
+}
+
+// failoverSentinels asks a sentinel to fail over, a failover already in progress is awaited.
+func (redis *RedisReconciler) failoverSentinels() error {
+	_, sentinelPodList, err := redis.GetDeploymentPods()
+	if err != nil {
+		return err
+	}
+
+	err = fmt.Errorf("no sentinel of %s is running", redis.name)
+	for _, pod := range sentinelPodList.Items {
+		client := rediscli.NewClient(&rediscli.Options{Addr: pod.Status.PodIP + ":" + RedisSentinelConnPort})
+		err = client.Do("SENTINEL", "FAILOVER", RedisSentinelConnGroup).Err()
+		client.Close()
+		if err == nil || strings.HasPrefix(err.Error(), "INPROG") {
+			return nil
+		}
+	}
+	return err
+}
+
+// getPodOrdinal returns the ordinal of a statefulset pod.
+func getPodOrdinal(name string) int {
+	ordinal, err := strconv.Atoi(name[strings.LastIndex(name, "-")+1:])
+	if err != nil {
+		return -1
+	}
+	return ordinal
+}
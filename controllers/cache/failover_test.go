@@ -0,0 +1,355 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	"github.com/goharbor/harbor-cluster-operator/controllers/k8s"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetPodOrdinal(t *testing.T) {
+	tests := []struct {
+		name string
+		pod  string
+		want int
+	}{
+		{
+			name: "first pod",
+			pod:  "rfr-harbor-redis-0",
+			want: 0,
+		},
+		{
+			name: "two digit ordinal",
+			pod:  "rfr-harbor-redis-12",
+			want: 12,
+		},
+		{
+			name: "no ordinal",
+			pod:  "rfs-harbor-redis-7d4b9c-x2x9z",
+			want: -1,
+		},
+		{
+			name: "no dash",
+			pod:  "redis",
+			want: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getPodOrdinal(tt.pod); got != tt.want {
+				t.Errorf("getPodOrdinal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func newRedisPod(name, ip string) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     corev1.PodStatus{PodIP: ip},
+	}
+}
+
+func TestGetRemovedPods(t *testing.T) {
+	pods := []corev1.Pod{
+		newRedisPod("rfr-harbor-redis-0", "10.0.0.1"),
+		newRedisPod("rfr-harbor-redis-1", "10.0.0.2"),
+		newRedisPod("rfr-harbor-redis-2", "10.0.0.3"),
+	}
+
+	tests := []struct {
+		name              string
+		replicas          int32
+		master            string
+		wantRemoved       []string
+		wantMasterRemoved bool
+	}{
+		{
+			name:        "master kept",
+			replicas:    2,
+			master:      "10.0.0.1",
+			wantRemoved: []string{"rfr-harbor-redis-2"},
+		},
+		{
+			name:              "master removed",
+			replicas:          2,
+			master:            "10.0.0.3",
+			wantRemoved:       []string{"rfr-harbor-redis-2"},
+			wantMasterRemoved: true,
+		},
+		{
+			name:              "master removed with a replica",
+			replicas:          1,
+			master:            "10.0.0.2",
+			wantRemoved:       []string{"rfr-harbor-redis-1", "rfr-harbor-redis-2"},
+			wantMasterRemoved: true,
+		},
+		{
+			name:     "no pod removed",
+			replicas: 3,
+			master:   "10.0.0.3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			removed, isMasterRemoved := getRemovedPods(pods, tt.replicas, tt.master)
+			if isMasterRemoved != tt.wantMasterRemoved {
+				t.Errorf("getRemovedPods() isMasterRemoved = %v, want %v", isMasterRemoved, tt.wantMasterRemoved)
+			}
+			var names []string
+			for _, pod := range removed {
+				names = append(names, pod.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.wantRemoved, ",") {
+				t.Errorf("getRemovedPods() = %v, want %v", names, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+// fakeRedisServer is a local stand-in of a redis server or sentinel, which answers the commands with the handler
+// and records them.
+type fakeRedisServer struct {
+	listener net.Listener
+	handler  func(args []string) interface{}
+
+	mu       sync.Mutex
+	commands []string
+}
+
+// newFakeRedisServer listens on the address of a pod, the pods are given loopback addresses such as 127.0.0.2
+// since the redis and sentinel ports are fixed.
+func newFakeRedisServer(t *testing.T, addr string, handler func(args []string) interface{}) *fakeRedisServer {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("can not listen on %s: %v", addr, err)
+	}
+
+	s := &fakeRedisServer{listener: listener, handler: handler}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeRedisServer) Close() {
+	s.listener.Close()
+}
+
+func (s *fakeRedisServer) getCommands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func (s *fakeRedisServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readRedisCommand(reader)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.commands = append(s.commands, strings.Join(args, " "))
+		s.mu.Unlock()
+
+		reply := "+OK\r\n"
+		if strings.ToUpper(args[0]) != "AUTH" {
+			reply = encodeRedisReply(s.handler(args))
+		}
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// readRedisCommand reads a command sent as an array of bulk strings.
+func readRedisCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("unexpected command %q", line)
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		line, err = reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+func encodeRedisReply(reply interface{}) string {
+	switch value := reply.(type) {
+	case nil:
+		return "+OK\r\n"
+	case error:
+		return "-" + value.Error() + "\r\n"
+	case string:
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case []interface{}:
+		encoded := fmt.Sprintf("*%d\r\n", len(value))
+		for _, item := range value {
+			encoded += encodeRedisReply(item)
+		}
+		return encoded
+	default:
+		return fmt.Sprintf("-ERR unexpected reply %v\r\n", value)
+	}
+}
+
+func TestFailoverFromRemovedPods(t *testing.T) {
+	const (
+		name        = "harbor-redis"
+		sentinelIP  = "127.0.0.21"
+		namespace   = "default"
+		newReplicas = 2
+	)
+	serverIPs := []string{"127.0.0.11", "127.0.0.12", "127.0.0.13"}
+
+	tests := []struct {
+		name         string
+		master       string
+		wantKept     bool
+		wantPriority []string
+	}{
+		{
+			name:     "master kept",
+			master:   "127.0.0.11",
+			wantKept: true,
+		},
+		{
+			name:         "master removed",
+			master:       "127.0.0.13",
+			wantKept:     false,
+			wantPriority: []string{"127.0.0.13"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sentinel := newFakeRedisServer(t, net.JoinHostPort(sentinelIP, RedisSentinelConnPort), func(args []string) interface{} {
+				if len(args) < 2 {
+					return nil
+				}
+				switch strings.ToLower(strings.Join(args[:2], " ")) {
+				case "sentinel get-master-addr-by-name":
+					return []interface{}{tt.master, RedisServerPort}
+				case "sentinel slaves":
+					return []interface{}{}
+				}
+				return nil
+			})
+			defer sentinel.Close()
+
+			servers := make(map[string]*fakeRedisServer)
+			for _, ip := range serverIPs {
+				server := newFakeRedisServer(t, net.JoinHostPort(ip, RedisServerPort), func(args []string) interface{} {
+					return nil
+				})
+				defer server.Close()
+				servers[ip] = server
+			}
+
+			labels := map[string]string{"app.kubernetes.io/name": name}
+			sentinelLabels := map[string]string{"app.kubernetes.io/name": name + "-sentinel"}
+			objects := []runtime.Object{
+				&appsv1.StatefulSet{
+					ObjectMeta: metav1.ObjectMeta{Name: "rfr-" + name, Namespace: namespace},
+					Spec:       appsv1.StatefulSetSpec{Selector: &metav1.LabelSelector{MatchLabels: labels}},
+				},
+				&appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Name: "rfs-" + name, Namespace: namespace},
+					Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: sentinelLabels}},
+				},
+				&corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: "rfs-" + name + "-7d4b9c-x2x9z", Namespace: namespace, Labels: sentinelLabels},
+					Status:     corev1.PodStatus{PodIP: sentinelIP},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+					Data:       map[string][]byte{"password": []byte("redis-secret")},
+				},
+			}
+			for i, ip := range serverIPs {
+				objects = append(objects, &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("rfr-%s-%d", name, i), Namespace: namespace, Labels: labels},
+					Status:     corev1.PodStatus{PodIP: ip},
+				})
+			}
+
+			redis := &RedisReconciler{
+				HarborCluster: &goharborv1.HarborCluster{ObjectMeta: metav1.ObjectMeta{Name: "harbor", Namespace: namespace}},
+				Client:        k8s.WrapClient(context.Background(), fake.NewFakeClientWithScheme(clientgoscheme.Scheme, objects...)),
+				Log:           ctrl.Log.WithName("test"),
+				name:          name,
+			}
+
+			isMasterKept, err := redis.failoverFromRemovedPods(newReplicas)
+			if err != nil {
+				t.Fatalf("failoverFromRemovedPods() error = %v", err)
+			}
+			if isMasterKept != tt.wantKept {
+				t.Errorf("failoverFromRemovedPods() = %v, want %v", isMasterKept, tt.wantKept)
+			}
+
+			var priorities []string
+			for _, ip := range serverIPs {
+				for _, command := range servers[ip].getCommands() {
+					if strings.EqualFold(command, "config set slave-priority 0") {
+						priorities = append(priorities, ip)
+					}
+				}
+			}
+			if strings.Join(priorities, ",") != strings.Join(tt.wantPriority, ",") {
+				t.Errorf("failoverFromRemovedPods() set slave-priority of %v, want %v", priorities, tt.wantPriority)
+			}
+
+			isFailedOver := false
+			for _, command := range sentinel.getCommands() {
+				if strings.EqualFold(command, "sentinel failover "+RedisSentinelConnGroup) {
+					isFailedOver = true
+				}
+			}
+			if isFailedOver == tt.wantKept {
+				t.Errorf("failoverFromRemovedPods() failover = %v, want %v", isFailedOver, !tt.wantKept)
+			}
+		})
+	}
+}
@@ -16,7 +16,6 @@ var (
 
 // generateRedisCR returns RedisFailovers CRs
 func (redis *RedisReconciler) generateRedisCR() (*unstructured.Unstructured, error) {
-	redisResource := redis.GetRedisResource()
 	redisRep := redis.GetRedisServerReplica()
 	sentinelRep := redis.GetRedisSentinelReplica()
 	storageSize := redis.GetRedisStorageSize()
@@ -27,30 +26,46 @@ func (redis *RedisReconciler) generateRedisCR() (*unstructured.Unstructured, err
 			APIVersion: "databases.spotahome.com/v1",
 		},
 		ObjectMeta: v1.ObjectMeta{
-			Name:      redis.HarborCluster.Name,
+			Name:      redis.name,
 			Namespace: redis.HarborCluster.Namespace,
 			Labels:    redis.Labels,
 		},
 		Spec: redisCli.RedisFailoverSpec{
 			Redis: redisCli.RedisSettings{
-				Replicas: redisRep,
-				Resources: corev1.ResourceRequirements{
-					Requests: redisResource,
-					Limits:   redisResource,
-				},
+				Replicas:  redisRep,
+				Resources: redis.GetRedisResources(),
+				// the master fails over before its pod is stopped.
+				ShutdownConfigMap: redis.getShutdownConfigMapName(),
 			},
 			Sentinel: redisCli.SentinelSettings{
-				Replicas: sentinelRep,
-				Resources: corev1.ResourceRequirements{
-					Requests: redisResource,
-					Limits:   redisResource,
-				},
+				Replicas:  sentinelRep,
+				Resources: redis.GetSentinelResources(),
 			},
-			Auth: redisCli.AuthSettings{SecretPath: redis.HarborCluster.Name},
+			Auth: redisCli.AuthSettings{SecretPath: redis.name},
 		},
 	}
 
-	conf.Spec.Redis.Storage.PersistentVolumeClaim = redis.generateRedisStorage(storageSize, redis.HarborCluster.Name)
+	if server := redis.spec.Server; server != nil {
+		conf.Spec.Redis.Image = server.Image
+		conf.Spec.Redis.CustomConfig = server.CustomConfig
+		conf.Spec.Redis.NodeSelector = server.NodeSelector
+		conf.Spec.Redis.Tolerations = server.Tolerations
+		conf.Spec.Redis.Affinity = server.Affinity
+	}
+
+	if sentinel := redis.spec.Sentinel; sentinel != nil {
+		conf.Spec.Sentinel.Image = sentinel.Image
+		conf.Spec.Sentinel.CustomConfig = sentinel.CustomConfig
+		conf.Spec.Sentinel.NodeSelector = sentinel.NodeSelector
+		conf.Spec.Sentinel.Tolerations = sentinel.Tolerations
+		conf.Spec.Sentinel.Affinity = sentinel.Affinity
+	}
+
+	if redis.spec.Server != nil && redis.spec.Server.Ephemeral {
+		conf.Spec.Redis.Storage.EmptyDir = &corev1.EmptyDirVolumeSource{}
+	} else {
+		conf.Spec.Redis.Storage.PersistentVolumeClaim = redis.generateRedisStorage(storageSize, redis.name)
+	}
 
 	mapResult, err := runtime.DefaultUnstructuredConverter.ToUnstructured(conf)
 	if err != nil {
@@ -69,7 +84,7 @@ func (redis *RedisReconciler) generateRedisSecret() *corev1.Secret {
 
 	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      redis.HarborCluster.Name,
+			Name:      redis.name,
 			Namespace: redis.HarborCluster.Namespace,
 			Labels:    redis.Labels,
 		},
@@ -81,6 +96,12 @@ func (redis *RedisReconciler) generateRedisSecret() *corev1.Secret {
 
 func (redis *RedisReconciler) generateRedisStorage(size, name string) *corev1.PersistentVolumeClaim {
 	storage, _ := resource.ParseQuantity(size)
+
+	var storageClassName *string
+	if redis.spec.Server != nil && redis.spec.Server.StorageClassName != "" {
+		storageClassName = &redis.spec.Server.StorageClassName
+	}
+
 	return &corev1.PersistentVolumeClaim{
 		ObjectMeta: v1.ObjectMeta{
 			Name:   name,
@@ -90,7 +111,8 @@ func (redis *RedisReconciler) generateRedisStorage(size, name string) *corev1.Pe
 			AccessModes: []corev1.PersistentVolumeAccessMode{
 				corev1.ReadWriteOnce,
 			},
-			Selector: nil,
+			Selector:         nil,
+			StorageClassName: storageClassName,
 			Resources: corev1.ResourceRequirements{
 				Requests: corev1.ResourceList{
 					"storage": storage,
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"time"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The default connection profile of the redis clients of the operator.
+// The clients are closed at the end of every reconcile, the idle connections are kept for some minutes
+// so that the checks of a reconcile reuse the connections of the pool instead of reconnecting.
+const (
+	DefaultRedisDialTimeout  = 10 * time.Second
+	DefaultRedisReadTimeout  = 30 * time.Second
+	DefaultRedisWriteTimeout = 30 * time.Second
+	DefaultRedisPoolTimeout  = 30 * time.Second
+	DefaultRedisIdleTimeout  = 5 * time.Minute
+)
+
+// connectionProfile is the connection profile of the redis clients with the defaults applied,
+// a zero pool size is the default of go-redis.
+type connectionProfile struct {
+	poolSize     int
+	maxRetries   int
+	dialTimeout  time.Duration
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	poolTimeout  time.Duration
+	idleTimeout  time.Duration
+}
+
+// getConnectionProfile returns the connection profile with the defaults of the unset settings,
+// the profile may be nil for the default profile.
+func getConnectionProfile(profile *goharborv1.RedisConnectionProfile) *connectionProfile {
+	if profile == nil {
+		profile = &goharborv1.RedisConnectionProfile{}
+	}
+
+	return &connectionProfile{
+		poolSize:     profile.PoolSize,
+		maxRetries:   profile.MaxRetries,
+		dialTimeout:  getDuration(profile.DialTimeout, DefaultRedisDialTimeout),
+		readTimeout:  getDuration(profile.ReadTimeout, DefaultRedisReadTimeout),
+		writeTimeout: getDuration(profile.WriteTimeout, DefaultRedisWriteTimeout),
+		poolTimeout:  getDuration(profile.PoolTimeout, DefaultRedisPoolTimeout),
+		idleTimeout:  getDuration(profile.IdleTimeout, DefaultRedisIdleTimeout),
+	}
+}
+
+func getDuration(duration *metav1.Duration, defaultDuration time.Duration) time.Duration {
+	if duration == nil {
+		return defaultDuration
+	}
+	return duration.Duration
+}
@@ -0,0 +1,24 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetConnectionProfile(t *testing.T) {
+	profile := getConnectionProfile(nil)
+	if profile.poolSize != 0 || profile.dialTimeout != DefaultRedisDialTimeout || profile.idleTimeout != DefaultRedisIdleTimeout {
+		t.Errorf("getConnectionProfile(nil) = %+v, want the defaults", profile)
+	}
+
+	profile = getConnectionProfile(&goharborv1.RedisConnectionProfile{
+		PoolSize:    20,
+		ReadTimeout: &metav1.Duration{Duration: time.Second},
+	})
+	if profile.poolSize != 20 || profile.readTimeout != time.Second || profile.writeTimeout != DefaultRedisWriteTimeout {
+		t.Errorf("getConnectionProfile() = %+v, want the pool size and read timeout set", profile)
+	}
+}
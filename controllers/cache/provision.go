@@ -1,6 +1,7 @@
 package cache
 
 import (
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
 	"github.com/goharbor/harbor-cluster-operator/lcm"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -21,7 +22,7 @@ import (
 // - perform any pod upgrade (left for rolling upgrade phase)
 func (redis *RedisReconciler) Deploy() (*lcm.CRStatus, error) {
 
-	if redis.HarborCluster.Spec.Redis.Kind == "external" {
+	if redis.kind == goharborv1.ExternalComponent {
 		return cacheUnknownStatus(), nil
 	}
 
@@ -42,14 +43,14 @@ func (redis *RedisReconciler) Deploy() (*lcm.CRStatus, error) {
 		return cacheNotReadyStatus(CreateRedisSecretError, err.Error()), err
 	}
 
-	redis.Log.Info("Creating Redis.", "namespace", redis.HarborCluster.Namespace, "name", redis.HarborCluster.Name)
+	redis.Log.Info("Creating Redis.", "namespace", redis.HarborCluster.Namespace, "name", redis.name)
 
 	_, err = crdClient.Create(expectCR, metav1.CreateOptions{})
 	if err != nil {
 		return cacheNotReadyStatus(CreateRedisCrError, err.Error()), err
 	}
 
-	redis.Log.Info("Redis has been created.", "namespace", redis.HarborCluster.Namespace, "name", redis.HarborCluster.Name)
+	redis.Log.Info("Redis has been created.", "namespace", redis.HarborCluster.Namespace, "name", redis.name)
 	return cacheUnknownStatus(), nil
 }
 
@@ -62,9 +63,9 @@ func (redis *RedisReconciler) DeploySecret() error {
 		return err
 	}
 
-	err := redis.Client.Get(types.NamespacedName{Name: redis.HarborCluster.Name, Namespace: redis.HarborCluster.Namespace}, secret)
+	err := redis.Client.Get(types.NamespacedName{Name: redis.name, Namespace: redis.HarborCluster.Namespace}, secret)
 	if err != nil && errors.IsNotFound(err) {
-		redis.Log.Info("Creating Redis Password Secret", "namespace", redis.HarborCluster.Namespace, "name", redis.HarborCluster.Name)
+		redis.Log.Info("Creating Redis Password Secret", "namespace", redis.HarborCluster.Namespace, "name", redis.name)
 		return redis.Client.Create(sc)
 	}
 
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	rediscli "github.com/go-redis/redis"
 	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	"github.com/goharbor/harbor-cluster-operator/controllers/common"
 	"github.com/goharbor/harbor-cluster-operator/lcm"
 	corev1 "k8s.io/api/core/v1"
 	kerr "k8s.io/apimachinery/pkg/api/errors"
@@ -20,19 +21,13 @@ const (
 	HarborRegistry    = "registry"
 )
 
-var (
-	components = []string{
-		HarborChartMuseum,
-		HarborClair,
-		HarborJobService,
-		HarborRegistry,
-	}
-)
-
 // Readiness reconcile will check Redis sentinel cluster if that has available.
 // It does:
 // - create redis connection pool
 // - ping redis server
+// - check the master is agreed by the sentinels, its replicas are in sync, and it is writable
+// - set the ACL user of every harbor component if enabled
+// - write the connection url of its own redis database and the key namespace of every harbor component
 // - return redis properties if redis has available
 func (redis *RedisReconciler) Readiness() (*lcm.CRStatus, error) {
 	var (
@@ -40,7 +35,7 @@ func (redis *RedisReconciler) Readiness() (*lcm.CRStatus, error) {
 		err    error
 	)
 
-	switch redis.HarborCluster.Spec.Redis.Kind {
+	switch redis.kind {
 	case goharborv1.ExternalComponent:
 		client, err = redis.GetExternalRedisInfo()
 	case goharborv1.InClusterComponent:
@@ -49,7 +44,7 @@ func (redis *RedisReconciler) Readiness() (*lcm.CRStatus, error) {
 
 	if err != nil {
 		redis.Log.Error(err, "Fail to create redis client.",
-			"namespace", redis.HarborCluster.Namespace, "name", redis.HarborCluster.Name)
+			"namespace", redis.HarborCluster.Namespace, "name", redis.name)
 		return cacheNotReadyStatus(GetRedisClientError, err.Error()), err
 	}
 
@@ -57,20 +52,48 @@ func (redis *RedisReconciler) Readiness() (*lcm.CRStatus, error) {
 
 	if err := client.Ping().Err(); err != nil {
 		redis.Log.Error(err, "Fail to check Redis.",
-			"namespace", redis.HarborCluster.Namespace, "name", redis.HarborCluster.Name)
+			"namespace", redis.HarborCluster.Namespace, "name", redis.name)
 		return cacheNotReadyStatus(CheckRedisHealthError, err.Error()), err
 	}
 
+	// the failures of the topology are reported in the CacheReady condition instead of being returned.
+	if reason, err := redis.checkTopology(client); err != nil {
+		redis.Log.Info("Redis topology check failed.",
+			"namespace", redis.HarborCluster.Namespace, "name", redis.name, "reason", reason, "error", err.Error())
+		return cacheNotReadyStatus(reason, err.Error()), nil
+	}
+
 	redis.Log.Info("Redis already ready.",
-		"namespace", redis.HarborCluster.Namespace, "name", redis.HarborCluster.Name)
+		"namespace", redis.HarborCluster.Namespace, "name", redis.name)
+
+	var passwords map[string]string
+	if redis.kind == goharborv1.InClusterComponent && redis.spec.ACLUsers {
+		passwords, err = redis.ensureACLPasswords()
+		if err != nil {
+			return cacheNotReadyStatus(CreateRedisACLPasswordError, err.Error()), err
+		}
+
+		// the redis of the image may not support ACL, which is reported in the CacheReady condition.
+		if err := redis.applyACLUsers(passwords); err != nil {
+			redis.Log.Info("Redis ACL users are not applied.",
+				"namespace", redis.HarborCluster.Namespace, "name", redis.name, "error", err.Error())
+			return cacheNotReadyStatus(ApplyRedisACLUsersError, err.Error()), nil
+		}
+	}
 
 	properties := lcm.Properties{}
-	for _, component := range components {
-		url := redis.RedisConnect.GenRedisConnURL()
+	for _, component := range redis.components {
+		database, namespace := redis.spec.GetComponentCache(component)
+		connect := *redis.RedisConnect
+		if password, ok := passwords[component]; ok {
+			connect.Username = getACLUsername(component)
+			connect.Password = password
+		}
+		url := connect.GenRedisConnURL(database)
 		secretName := fmt.Sprintf("%s-redis", strings.ToLower(component))
 		propertyName := fmt.Sprintf("%sSecret", component)
 
-		if err := redis.DeployComponentSecret(component, url, "", secretName); err != nil {
+		if err := redis.DeployComponentSecret(component, url, namespace, secretName); err != nil {
 			return cacheNotReadyStatus(CreateComponentSecretError, err.Error()), err
 		}
 
@@ -86,7 +109,7 @@ func (redis *RedisReconciler) DeployComponentSecret(component, url, namespace, s
 
 	sc := redis.generateHarborCacheSecret(component, secretName, url, namespace)
 
-	switch redis.HarborCluster.Spec.Redis.Kind {
+	switch redis.kind {
 	case goharborv1.ExternalComponent:
 		if err := controllerutil.SetControllerReference(redis.HarborCluster, sc, redis.Scheme); err != nil {
 			return err
@@ -108,62 +131,76 @@ func (redis *RedisReconciler) DeployComponentSecret(component, url, namespace, s
 			"name", secretName,
 			"component", component)
 		return redis.Client.Create(sc)
+	} else if err != nil {
+		return err
 	}
 
-	return err
+	// the connection url changes when the redis password is rotated
+	if common.IsSecretUpToDate(secret, sc.StringData) {
+		return nil
+	}
+
+	redis.Log.Info("Updating Harbor Component Secret",
+		"namespace", redis.HarborCluster.Namespace,
+		"name", secretName,
+		"component", component)
+	secret.Data = nil
+	secret.StringData = sc.StringData
+	return redis.Client.Update(secret)
 }
 
 func (redis *RedisReconciler) GetExternalRedisInfo() (*rediscli.Client, error) {
 	var (
-		connect  *RedisConnect
-		endpoint []string
-		port     string
-		client   *rediscli.Client
-		err      error
-		pw       string
+		connect = &RedisConnect{}
+		client  *rediscli.Client
+		err     error
 	)
-	spec := redis.HarborCluster.Spec.Redis.Spec
+	spec := redis.spec
+
+	tlsConfig, err := redis.GetRedisTLSConfig(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.SecretName != "" {
+		connect, err = redis.GetExternalRedisCredentials(spec)
+		if err != nil {
+			return nil, err
+		}
+	}
+	connect.Profile = spec.GetConnectionProfile()
+
 	switch spec.Schema {
 	case RedisSentinelSchema:
 		if len(spec.Hosts) < 1 || spec.GroupName == "" {
 			return nil, errors.New(".redis.spec.hosts or .redis.spec.groupName is invalid")
 		}
 
-		endpoint, port = GetExternalRedisHost(spec)
-
-		if spec.SecretName != "" {
-			pw, err = redis.GetExternalRedisPassword(spec)
-		}
-
-		connect = &RedisConnect{
-			Endpoints: endpoint,
-			Port:      port,
-			Password:  pw,
-			GroupName: spec.GroupName,
-			Schema:    RedisSentinelSchema,
-		}
+		connect.Endpoints, connect.Port = GetExternalRedisHost(spec)
+		connect.GroupName = spec.GroupName
+		connect.Schema = RedisSentinelSchema
+		connect.SentinelAddrs = GetExternalRedisAddrs(spec)
 
 		redis.RedisConnect = connect
-		client = connect.NewRedisPool()
+		if connect.SentinelPassword != "" {
+			client, err = connect.NewRedisMasterClient()
+		} else {
+			client = connect.NewRedisPool()
+		}
 	case RedisServerSchema:
 		if len(spec.Hosts) != 1 {
 			return nil, errors.New(".redis.spec.hosts is invalid")
 		}
-		endpoint, port = GetExternalRedisHost(spec)
 
-		if spec.SecretName != "" {
-			pw, err = redis.GetExternalRedisPassword(spec)
-		}
+		connect.Endpoints, connect.Port = GetExternalRedisHost(spec)
+		connect.GroupName = spec.GroupName
+		connect.Schema = RedisServerSchema
+		connect.TLSConfig = tlsConfig
 
-		connect = &RedisConnect{
-			Endpoints: endpoint,
-			Port:      port,
-			Password:  pw,
-			GroupName: spec.GroupName,
-			Schema:    RedisServerSchema,
-		}
 		redis.RedisConnect = connect
 		client = connect.NewRedisClient()
+	default:
+		return nil, fmt.Errorf(".redis.spec.schema %s is not supported", spec.Schema)
 	}
 
 	if err != nil {
@@ -187,20 +224,33 @@ func GetExternalRedisHost(spec *goharborv1.RedisSpec) ([]string, string) {
 	return endpoint, port
 }
 
-// GetExternalRedisPassword returns external redis password
-func (redis *RedisReconciler) GetExternalRedisPassword(spec *goharborv1.RedisSpec) (string, error) {
+// GetExternalRedisAddrs returns the "host:port" of every external redis host
+func GetExternalRedisAddrs(spec *goharborv1.RedisSpec) []string {
+	var addrs []string
+	for _, host := range spec.Hosts {
+		addrs = append(addrs, GenHostInfo([]string{host.Host}, host.Port)...)
+	}
+	return addrs
+}
 
-	pw, err := redis.GetRedisPassword(spec.SecretName)
+// GetExternalRedisCredentials returns the ACL username, the password and the sentinel password of the external redis,
+// the username and the sentinel password are optional.
+func (redis *RedisReconciler) GetExternalRedisCredentials(spec *goharborv1.RedisSpec) (*RedisConnect, error) {
+	data, err := redis.GetRedisSecret(spec.SecretName)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return pw, err
+	return &RedisConnect{
+		Username:         string(data["username"]),
+		Password:         string(data["password"]),
+		SentinelPassword: string(data["sentinelPassword"]),
+	}, nil
 }
 
 // GetInClusterRedisInfo returns inCluster redis sentinel pool client
 func (redis *RedisReconciler) GetInClusterRedisInfo() (*rediscli.Client, error) {
-	password, err := redis.GetRedisPassword(redis.HarborCluster.Name)
+	password, err := redis.GetRedisPassword(redis.name)
 	if err != nil {
 		return nil, err
 	}
@@ -232,11 +282,19 @@ func (redis *RedisReconciler) GetInClusterRedisInfo() (*rediscli.Client, error)
 
 	endpoint := redis.GetSentinelServiceUrl(currentSentinelPods)
 
+	var sentinelAddrs []string
+	for _, pod := range currentSentinelPods {
+		sentinelAddrs = append(sentinelAddrs, pod.Status.PodIP+":"+RedisSentinelConnPort)
+	}
+
 	connect := &RedisConnect{
-		Endpoints: []string{endpoint},
-		Port:      RedisSentinelConnPort,
-		Password:  password,
-		GroupName: RedisSentinelConnGroup,
+		Endpoints:     []string{endpoint},
+		Port:          RedisSentinelConnPort,
+		Password:      password,
+		GroupName:     RedisSentinelConnGroup,
+		Schema:        RedisSentinelSchema,
+		SentinelAddrs: sentinelAddrs,
+		Profile:       redis.spec.GetConnectionProfile(),
 	}
 
 	redis.RedisConnect = connect
@@ -6,6 +6,7 @@ import (
 	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
 	"github.com/goharbor/harbor-cluster-operator/controllers/k8s"
 	"github.com/goharbor/harbor-cluster-operator/lcm"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -27,6 +28,12 @@ type RedisReconciler struct {
 	ActualCR      *unstructured.Unstructured
 	Labels        map[string]string
 	RedisConnect  *RedisConnect
+
+	// the redis managed by the reconciler, either the redis of .redis.spec or a dedicated redis.
+	name       string
+	kind       string
+	spec       *goharborv1.RedisSpec
+	components []string
 }
 
 // Reconciler implements the reconcile logic of redis service
@@ -35,10 +42,32 @@ func (redis *RedisReconciler) Reconcile() (*lcm.CRStatus, error) {
 	redis.Client.WithContext(redis.CXT)
 	redis.DClient.WithContext(redis.CXT)
 
+	properties := lcm.Properties{}
+	for _, instance := range redis.getInstances() {
+		crStatus, err := instance.reconcileInstance()
+		if err != nil || crStatus.Condition.Status != corev1.ConditionTrue {
+			return crStatus, err
+		}
+		properties = append(properties, crStatus.Properties...)
+	}
+
+	if err := redis.deleteStaleInstances(); err != nil {
+		return cacheNotReadyStatus(DeleteRedisCrError, err.Error()), err
+	}
+
+	return cacheReadyStatus(&properties), nil
+}
+
+// reconcileInstance reconciles the redis managed by the reconciler, and writes the secrets of its components.
+func (redis *RedisReconciler) reconcileInstance() (*lcm.CRStatus, error) {
 	crdClient := redis.DClient.WithResource(redisFailoversGVR).WithNamespace(redis.HarborCluster.Namespace)
 
-	if redis.HarborCluster.Spec.Redis.Kind == goharborv1.InClusterComponent {
-		actualCR, err := crdClient.Get(redis.HarborCluster.Name, metav1.GetOptions{})
+	if redis.kind == goharborv1.InClusterComponent {
+		if err := redis.ensureShutdownConfigMap(); err != nil {
+			return cacheNotReadyStatus(CreateRedisShutdownConfigMapError, err.Error()), err
+		}
+
+		actualCR, err := crdClient.Get(redis.name, metav1.GetOptions{})
 		if errors.IsNotFound(err) {
 			return redis.Provision()
 		} else if err != nil {
@@ -58,7 +87,7 @@ func (redis *RedisReconciler) Reconcile() (*lcm.CRStatus, error) {
 		redis.ExpectCR = expectCR
 
 		crStatus, err := redis.Update(nil)
-		if err != nil {
+		if err != nil || crStatus.Condition.Status == corev1.ConditionFalse {
 			return crStatus, err
 		}
 	}
@@ -79,12 +108,25 @@ func (redis *RedisReconciler) Provision() (*lcm.CRStatus, error) {
 	return crStatus, nil
 }
 
+// Delete deletes the inCluster redis, the secrets and volumes are owned by the redis or the harbor cluster.
 func (redis *RedisReconciler) Delete() (*lcm.CRStatus, error) {
-	panic("implement me")
+	for _, instance := range redis.getInstances() {
+		if instance.kind != goharborv1.InClusterComponent {
+			continue
+		}
+
+		crdClient := redis.DClient.WithResource(redisFailoversGVR).WithNamespace(redis.HarborCluster.Namespace)
+		err := crdClient.Delete(instance.name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return cacheNotReadyStatus(DeleteRedisCrError, err.Error()), err
+		}
+	}
+	return cacheUnknownStatus(), nil
 }
 
+// Scale scales the redis as the rolling upgrades do, the master is moved away from the removed pods first.
 func (redis *RedisReconciler) Scale() (*lcm.CRStatus, error) {
-	panic("implement me")
+	return redis.RollingUpgrades()
 }
 
 func (redis *RedisReconciler) Update(spec *goharborv1.HarborCluster) (*lcm.CRStatus, error) {
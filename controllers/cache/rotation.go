@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+
+	rediscli "github.com/go-redis/redis"
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	RedisServerPort = "6379"
+
+	// redisPendingPasswordKey keeps the new password in the redis password secret until the rotation is done,
+	// so that a failed rotation is resumed with the same password.
+	redisPendingPasswordKey = "pendingPassword"
+	// redisRetiredPasswordKey keeps the replaced password in the redis password secret until harbor is restarted.
+	redisRetiredPasswordKey = "retiredPassword"
+	redisPasswordLength     = 16
+)
+
+// RotateCredentials rotates the password of the inCluster redis.
+// The replaced password is kept valid until the harbor pods are restarted, then it is removed by
+// RemoveRetiredCredentials. It does:
+// - add the new password to the default user of every redis server
+// - set the new password as masterauth of every redis server, so that replication survives the change
+// - set the new password as auth-pass of the master monitored by every sentinel
+// - update the redis password secret, the component secrets are updated by the next readiness check
+// Only redis 6 keeps several passwords, so the password of an older redis is not rotated.
+func (redis *RedisReconciler) RotateCredentials() error {
+	for _, instance := range redis.getInstances() {
+		if instance.kind != goharborv1.InClusterComponent {
+			continue
+		}
+		if err := instance.rotateCredentials(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (redis *RedisReconciler) rotateCredentials() error {
+	secret, err := redis.getPasswordSecret()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := secret.Data[redisRetiredPasswordKey]; ok {
+		redis.Log.Info("Skip rotating the redis password, the previous rotation is not finished", "namespace", redis.HarborCluster.Namespace, "name", redis.name)
+		return nil
+	}
+
+	password := string(secret.Data["password"])
+	newPassword := string(secret.Data[redisPendingPasswordKey])
+	if newPassword == "" {
+		newPassword = RandomString(redisPasswordLength, "a")
+		secret.Data[redisPendingPasswordKey] = []byte(newPassword)
+		if err := redis.Client.Update(secret); err != nil {
+			return err
+		}
+	}
+
+	redisPods, err := redis.getRunningRedisPods()
+	if err != nil {
+		return err
+	}
+
+	for _, pod := range redisPods {
+		err := addRedisPassword(pod.Status.PodIP, newPassword, password, newPassword)
+		if isUnknownCommand(err) {
+			redis.Log.Info("Skip rotating the redis password, the redis version does not support several passwords", "namespace", redis.HarborCluster.Namespace, "name", redis.name)
+			delete(secret.Data, redisPendingPasswordKey)
+			return redis.Client.Update(secret)
+		}
+		if err != nil {
+			return fmt.Errorf("add the password of redis %s: %w", pod.Name, err)
+		}
+	}
+
+	for _, pod := range redisPods {
+		if err := configSetRedis(pod.Status.PodIP, "masterauth", newPassword, newPassword); err != nil {
+			return fmt.Errorf("set masterauth of redis %s: %w", pod.Name, err)
+		}
+	}
+
+	_, sentinelPodList, err := redis.GetDeploymentPods()
+	if err != nil {
+		return err
+	}
+	_, sentinelPods := redis.GetPodsStatus(sentinelPodList.Items)
+
+	for _, pod := range sentinelPods {
+		client := rediscli.NewClient(&rediscli.Options{Addr: pod.Status.PodIP + ":" + RedisSentinelConnPort})
+		err := client.Do("SENTINEL", "SET", RedisSentinelConnGroup, "auth-pass", newPassword).Err()
+		client.Close()
+		if err != nil {
+			return fmt.Errorf("set auth-pass of sentinel %s: %w", pod.Name, err)
+		}
+	}
+
+	redis.Log.Info("Redis password rotated", "namespace", redis.HarborCluster.Namespace, "name", redis.name)
+
+	delete(secret.Data, redisPendingPasswordKey)
+	secret.Data["password"] = []byte(newPassword)
+	secret.Data[redisRetiredPasswordKey] = []byte(password)
+	return redis.Client.Update(secret)
+}
+
+// RemoveRetiredCredentials removes the redis passwords replaced by the rotations, once harbor is restarted.
+// Setting requirepass resets the passwords of the default user to the current one.
+// It must be called after Reconcile reports the cache ready.
+func (redis *RedisReconciler) RemoveRetiredCredentials() error {
+	for _, instance := range redis.getInstances() {
+		if instance.kind != goharborv1.InClusterComponent {
+			continue
+		}
+		if err := instance.removeRetiredPassword(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (redis *RedisReconciler) removeRetiredPassword() error {
+	secret, err := redis.getPasswordSecret()
+	if err != nil {
+		return err
+	}
+
+	retired, ok := secret.Data[redisRetiredPasswordKey]
+	if !ok {
+		return nil
+	}
+
+	redisPods, err := redis.getRunningRedisPods()
+	if err != nil {
+		return err
+	}
+
+	password := string(secret.Data["password"])
+	for _, pod := range redisPods {
+		if err := configSetRedis(pod.Status.PodIP, "requirepass", password, password, string(retired)); err != nil {
+			return fmt.Errorf("remove the retired password of redis %s: %w", pod.Name, err)
+		}
+	}
+
+	redis.Log.Info("Retired redis password removed", "namespace", redis.HarborCluster.Namespace, "name", redis.name)
+
+	delete(secret.Data, redisRetiredPasswordKey)
+	return redis.Client.Update(secret)
+}
+
+func (redis *RedisReconciler) getPasswordSecret() (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	err := redis.Client.Get(types.NamespacedName{Name: redis.name, Namespace: redis.HarborCluster.Namespace}, secret)
+	if err != nil {
+		return nil, err
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	return secret, nil
+}
+
+func (redis *RedisReconciler) getRunningRedisPods() ([]corev1.Pod, error) {
+	_, redisPodList, err := redis.GetStatefulSetPods()
+	if err != nil {
+		return nil, err
+	}
+	_, redisPods := redis.GetPodsStatus(redisPodList.Items)
+	return redisPods, nil
+}
+
+// addRedisPassword adds the password to the default user of the redis server, authenticated by the first valid password.
+func addRedisPassword(host, password string, passwords ...string) error {
+	var err error
+	for _, auth := range passwords {
+		client := BuildRedisClient([]string{host}, RedisServerPort, "", auth, 0, nil, nil)
+		err = client.Do("ACL", "SETUSER", "default", ">"+password).Err()
+		client.Close()
+		if err == nil || isUnknownCommand(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// isUnknownCommand checks whether the error is returned by a redis which does not support the command.
+func isUnknownCommand(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "ERR unknown command")
+}
+
+// configSetRedis sets the config parameter of the redis server, authenticated by the first valid password.
+func configSetRedis(host, parameter, value string, passwords ...string) error {
+	var err error
+	for _, password := range passwords {
+		client := BuildRedisClient([]string{host}, RedisServerPort, "", password, 0, nil, nil)
+		err = client.ConfigSet(parameter, value).Err()
+		client.Close()
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIsUnknownCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "no error",
+			want: false,
+		},
+		{
+			name: "redis 5 without acl",
+			err:  errors.New("ERR unknown command `ACL`, with args beginning with: `SETUSER`, `default`, "),
+			want: true,
+		},
+		{
+			name: "wrong password",
+			err:  errors.New("WRONGPASS invalid username-password pair"),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isUnknownCommand(tt.err); got != tt.want {
+				t.Errorf("isUnknownCommand() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+)
+
+const (
+	tlsCertKey = "tls.crt"
+	tlsKeyKey  = "tls.key"
+	caCertKey  = "ca.crt"
+)
+
+// GetRedisTLSConfig returns the TLS config of the connections to the external redis, or nil if TLS is not enabled.
+// The secret of .redis.spec.tlsConfig may contain "ca.crt" to verify the redis server, the system roots are
+// used otherwise, and "tls.crt" with "tls.key" when the redis server requires a client certificate.
+func (redis *RedisReconciler) GetRedisTLSConfig(spec *goharborv1.RedisSpec) (*tls.Config, error) {
+	if spec.TlsConfig == "" {
+		return nil, nil
+	}
+	if spec.Schema == RedisSentinelSchema {
+		return nil, errors.New(".redis.spec.tlsConfig is not supported with the sentinel schema")
+	}
+
+	data, err := redis.GetRedisSecret(spec.TlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if ca, ok := data[caCertKey]; ok {
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in %s of secret %s", caCertKey, spec.TlsConfig)
+		}
+	}
+
+	cert, hasCert := data[tlsCertKey]
+	key, hasKey := data[tlsKeyKey]
+	if hasCert != hasKey {
+		return nil, fmt.Errorf("secret %s must contain both %s and %s for the client certificate", spec.TlsConfig, tlsCertKey, tlsKeyKey)
+	}
+	if hasCert {
+		certificate, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
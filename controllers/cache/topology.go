@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	rediscli "github.com/go-redis/redis"
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+)
+
+const (
+	// RedisReplicationLagThreshold is the maximum replication offset lag in bytes of a replica in sync with the master.
+	RedisReplicationLagThreshold = 1024 * 1024
+
+	// redisReadinessKey is written to check the master is writable.
+	redisReadinessKey = "harbor-cluster-operator:readiness"
+)
+
+// checkTopology check the redis serving the components, and returns the reason of the failed check.
+// It does:
+// - check a master is elected, and every sentinel agrees on it with a reachable quorum
+// - check the replicas known by the sentinels are connected to the master and in sync
+// - check the master is writable
+// A split-brain sentinel answers ping, but is caught by the disagreement on the master.
+func (redis *RedisReconciler) checkTopology(client rediscli.UniversalClient) (string, error) {
+	if redis.RedisConnect.Schema == RedisSentinelSchema {
+		replicas, reason, err := redis.checkSentinelMaster()
+		if err != nil {
+			return reason, err
+		}
+
+		err = checkRedisReplicas(client, replicas)
+		if err != nil {
+			return RedisReplicasNotInSyncError, err
+		}
+	}
+
+	err := client.Set(redisReadinessKey, time.Now().Unix(), time.Minute).Err()
+	if err != nil {
+		return RedisMasterNotWritableError, err
+	}
+	return "", nil
+}
+
+// checkSentinelMaster check that every sentinel agrees on the master with a reachable quorum,
+// and returns the number of replicas expected to be in sync.
+func (redis *RedisReconciler) checkSentinelMaster() (int, string, error) {
+	group := redis.RedisConnect.GroupName
+	sentinelAddrs := redis.RedisConnect.SentinelAddrs
+	sentinelPassword := redis.RedisConnect.SentinelPassword
+
+	masters := make(map[string][]string)
+	replicas := 0
+	for _, addr := range sentinelAddrs {
+		master, knownReplicas, err := getSentinelMaster(addr, group, sentinelPassword)
+		if err != nil {
+			redis.Log.Info("Fail to get the master from sentinel.", "sentinel", addr, "error", err.Error())
+			continue
+		}
+		masters[master] = append(masters[master], addr)
+		if knownReplicas > replicas {
+			replicas = knownReplicas
+		}
+	}
+
+	switch {
+	case len(masters) == 0:
+		return 0, RedisMasterNotElectedError, fmt.Errorf("no sentinel knows the master of %s", group)
+	case len(masters) > 1:
+		return 0, RedisSentinelQuorumError, fmt.Errorf("sentinels disagree on the master of %s: %v", group, masters)
+	}
+
+	for master, agreed := range masters {
+		if len(agreed) <= len(sentinelAddrs)/2 {
+			return 0, RedisSentinelQuorumError, fmt.Errorf("only %d of %d sentinels agree on the master %s", len(agreed), len(sentinelAddrs), master)
+		}
+	}
+
+	if redis.kind == goharborv1.InClusterComponent {
+		if expected := int(redis.GetRedisServerReplica()) - 1; expected > replicas {
+			replicas = expected
+		}
+	}
+	return replicas, "", nil
+}
+
+// getSentinelMaster returns the master of the group and the number of its healthy replicas known by the sentinel,
+// after checking the sentinel reaches the quorum needed to failover.
+func getSentinelMaster(addr, group, password string) (string, int, error) {
+	client := newSentinelClient(addr, password)
+	defer client.Close()
+
+	result, err := client.Do("SENTINEL", "get-master-addr-by-name", group).Result()
+	if err != nil {
+		return "", 0, err
+	}
+	addrs, ok := result.([]interface{})
+	if !ok || len(addrs) != 2 {
+		return "", 0, fmt.Errorf("unexpected master address %v", result)
+	}
+	master := net.JoinHostPort(fmt.Sprint(addrs[0]), fmt.Sprint(addrs[1]))
+
+	if err := client.Do("SENTINEL", "ckquorum", group).Err(); err != nil {
+		return "", 0, err
+	}
+
+	result, err = client.Do("SENTINEL", "slaves", group).Result()
+	if err != nil {
+		return "", 0, err
+	}
+
+	return master, countHealthyReplicas(result), nil
+}
+
+// countHealthyReplicas returns the number of replicas of the SENTINEL slaves reply which are not flagged down or
+// disconnected. The sentinel keeps reporting a removed replica with these flags until it is reset.
+func countHealthyReplicas(result interface{}) int {
+	replicas, _ := result.([]interface{})
+
+	healthy := 0
+	for _, replica := range replicas {
+		fields, _ := replica.([]interface{})
+		flags := ""
+		for i := 0; i+1 < len(fields); i += 2 {
+			if fmt.Sprint(fields[i]) == "flags" {
+				flags = fmt.Sprint(fields[i+1])
+			}
+		}
+
+		isHealthy := flags != ""
+		for _, flag := range strings.Split(flags, ",") {
+			if flag == "s_down" || flag == "o_down" || flag == "disconnected" {
+				isHealthy = false
+			}
+		}
+		if isHealthy {
+			healthy++
+		}
+	}
+	return healthy
+}
+
+// checkRedisReplicas check that the master has at least the expected number of online replicas,
+// with a replication offset lag under RedisReplicationLagThreshold.
+func checkRedisReplicas(client rediscli.UniversalClient, expected int) error {
+	info, err := client.Info("replication").Result()
+	if err != nil {
+		return err
+	}
+	return checkReplicationInfo(info, expected)
+}
+
+// checkReplicationInfo check the replicas of the master in the replication section of the INFO reply.
+func checkReplicationInfo(info string, expected int) error {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(info, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(parts) == 2 {
+			fields[parts[0]] = parts[1]
+		}
+	}
+
+	if fields["role"] != "master" {
+		return fmt.Errorf("the redis of the sentinels master has the role %s", fields["role"])
+	}
+	masterOffset, err := strconv.ParseInt(fields["master_repl_offset"], 10, 64)
+	if err != nil {
+		return fmt.Errorf("parse master_repl_offset: %w", err)
+	}
+
+	connected, _ := strconv.Atoi(fields["connected_slaves"])
+	inSync := 0
+	for i := 0; i < connected; i++ {
+		replica := make(map[string]string)
+		for _, pair := range strings.Split(fields[fmt.Sprintf("slave%d", i)], ",") {
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) == 2 {
+				replica[parts[0]] = parts[1]
+			}
+		}
+
+		offset, err := strconv.ParseInt(replica["offset"], 10, 64)
+		if err == nil && replica["state"] == "online" && masterOffset-offset <= RedisReplicationLagThreshold {
+			inSync++
+		}
+	}
+
+	if inSync < expected {
+		return fmt.Errorf("%d of %d replicas are in sync with the master", inSync, expected)
+	}
+	return nil
+}
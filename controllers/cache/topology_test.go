@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCountHealthyReplicas(t *testing.T) {
+	replica := func(flags string) interface{} {
+		return []interface{}{"name", "10.0.0.2:6379", "ip", "10.0.0.2", "port", "6379", "flags", flags}
+	}
+
+	tests := []struct {
+		name   string
+		result interface{}
+		want   int
+	}{
+		{
+			name:   "no replica",
+			result: []interface{}{},
+			want:   0,
+		},
+		{
+			name:   "healthy replicas",
+			result: []interface{}{replica("slave"), replica("slave")},
+			want:   2,
+		},
+		{
+			name:   "subjectively down replica",
+			result: []interface{}{replica("slave"), replica("s_down,slave")},
+			want:   1,
+		},
+		{
+			name:   "disconnected replica",
+			result: []interface{}{replica("s_down,slave,disconnected"), replica("slave,disconnected")},
+			want:   0,
+		},
+		{
+			name:   "objectively down replica",
+			result: []interface{}{replica("o_down,slave"), replica("slave")},
+			want:   1,
+		},
+		{
+			name:   "replica without flags",
+			result: []interface{}{[]interface{}{"name", "10.0.0.2:6379"}},
+			want:   0,
+		},
+		{
+			name:   "unexpected reply",
+			result: "OK",
+			want:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countHealthyReplicas(tt.result); got != tt.want {
+				t.Errorf("countHealthyReplicas() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckReplicationInfo(t *testing.T) {
+	info := func(lines ...string) string {
+		return "# Replication\r\n" + strings.Join(lines, "\r\n") + "\r\n"
+	}
+
+	tests := []struct {
+		name     string
+		info     string
+		expected int
+		wantErr  bool
+	}{
+		{
+			name: "replicas in sync",
+			info: info("role:master", "connected_slaves:2",
+				"slave0:ip=10.0.0.2,port=6379,state=online,offset=1000,lag=0",
+				"slave1:ip=10.0.0.3,port=6379,state=online,offset=990,lag=1",
+				"master_repl_offset:1000"),
+			expected: 2,
+		},
+		{
+			name:     "no replica expected",
+			info:     info("role:master", "connected_slaves:0", "master_repl_offset:0"),
+			expected: 0,
+		},
+		{
+			name:     "not a master",
+			info:     info("role:slave", "master_host:10.0.0.1", "master_repl_offset:1000"),
+			expected: 0,
+			wantErr:  true,
+		},
+		{
+			name:     "missing master offset",
+			info:     info("role:master", "connected_slaves:0"),
+			expected: 0,
+			wantErr:  true,
+		},
+		{
+			name: "replica not online",
+			info: info("role:master", "connected_slaves:2",
+				"slave0:ip=10.0.0.2,port=6379,state=online,offset=1000,lag=0",
+				"slave1:ip=10.0.0.3,port=6379,state=wait_bgsave,offset=0,lag=0",
+				"master_repl_offset:1000"),
+			expected: 2,
+			wantErr:  true,
+		},
+		{
+			name: "replica lagging",
+			info: info("role:master", "connected_slaves:1",
+				"slave0:ip=10.0.0.2,port=6379,state=online,offset=1000,lag=0",
+				"master_repl_offset:2000000"),
+			expected: 1,
+			wantErr:  true,
+		},
+		{
+			name: "fewer replicas connected",
+			info: info("role:master", "connected_slaves:1",
+				"slave0:ip=10.0.0.2,port=6379,state=online,offset=1000,lag=0",
+				"master_repl_offset:1000"),
+			expected: 2,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkReplicationInfo(tt.info, tt.expected)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkReplicationInfo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
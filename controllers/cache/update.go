@@ -17,7 +17,9 @@ import (
 // RollingUpgrades reconcile will rolling upgrades Redis sentinel cluster if resource upscale.
 // It does:
 // - check resource
+// - fail over the master away from the pods removed by a scale-down, and wait for the new master
 // - update RedisFailovers CR resource
+// The restarted master fails over by its shutdown script, see generateRedisShutdownConfigMap.
 func (redis *RedisReconciler) RollingUpgrades() (*lcm.CRStatus, error) {
 
 	crdClient := redis.DClient.WithResource(redisFailoversGVR).WithNamespace(redis.HarborCluster.Namespace)
@@ -38,13 +40,29 @@ func (redis *RedisReconciler) RollingUpgrades() (*lcm.CRStatus, error) {
 		return cacheNotReadyStatus(DefaultUnstructuredConverterError, err.Error()), err
 	}
 
+	// the volume claim templates of the redis statefulset can not be changed once created.
+	expectCR.Spec.Redis.Storage = actualCR.Spec.Redis.Storage
+
+	if expectCR.Spec.Redis.Replicas < actualCR.Spec.Redis.Replicas {
+		isMasterKept, err := redis.failoverFromRemovedPods(expectCR.Spec.Redis.Replicas)
+		if err != nil {
+			return cacheNotReadyStatus(ManualFailoverRedisError, err.Error()), err
+		}
+		if !isMasterKept {
+			return cacheNotReadyStatus(RedisFailingOver, "waiting for a new master before scaling down"), nil
+		}
+
+		msg := fmt.Sprintf(MessageRedisDownScaling, actualCR.Spec.Redis.Replicas, expectCR.Spec.Redis.Replicas)
+		redis.Recorder.Event(redis.HarborCluster, corev1.EventTypeNormal, RedisDownScaling, msg)
+	}
+
 	if !IsEqual(expectCR, actualCR) {
-		msg := fmt.Sprintf(UpdateMessageRedisCluster, redis.HarborCluster.Name)
+		msg := fmt.Sprintf(UpdateMessageRedisCluster, redis.name)
 		redis.Recorder.Event(redis.HarborCluster, corev1.EventTypeNormal, RedisUpScaling, msg)
 
 		redis.Log.Info(
 			"Update Redis resource",
-			"namespace", redis.HarborCluster.Namespace, "name", redis.HarborCluster.Name,
+			"namespace", redis.HarborCluster.Namespace, "name", redis.name,
 		)
 
 		if err := Update(crdClient, actualCR, expectCR); err != nil {
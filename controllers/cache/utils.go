@@ -29,7 +29,7 @@ const (
 
 // GetRedisName returns the name for redis resources
 func (redis *RedisReconciler) GetRedisName() string {
-	return generateName(ReidsType, redis.GetHarborClusterName())
+	return generateName(ReidsType, redis.name)
 }
 
 func generateName(typeName, metaName string) string {
@@ -99,7 +99,7 @@ func (redis *RedisReconciler) GetRedisSecret(secretName string) (map[string][]by
 // GetDeploymentPods returns the Redis Sentinel pod list
 func (redis *RedisReconciler) GetDeploymentPods() (*appsv1.Deployment, *corev1.PodList, error) {
 	deploy := &appsv1.Deployment{}
-	name := fmt.Sprintf("%s-%s", "rfs", redis.HarborCluster.Name)
+	name := fmt.Sprintf("%s-%s", "rfs", redis.name)
 
 	err := redis.Client.Get(types.NamespacedName{Name: name, Namespace: redis.HarborCluster.Namespace}, deploy)
 	if err != nil {
@@ -122,7 +122,7 @@ func (redis *RedisReconciler) GetDeploymentPods() (*appsv1.Deployment, *corev1.P
 // GetStatefulSetPods returns the Redis Server pod list
 func (redis *RedisReconciler) GetStatefulSetPods() (*appsv1.StatefulSet, *corev1.PodList, error) {
 	sts := &appsv1.StatefulSet{}
-	name := fmt.Sprintf("%s-%s", "rfr", redis.HarborCluster.Name)
+	name := fmt.Sprintf("%s-%s", "rfr", redis.name)
 
 	err := redis.Client.Get(types.NamespacedName{Name: name, Namespace: redis.HarborCluster.Namespace}, sts)
 	if err != nil {
@@ -150,7 +150,7 @@ func (redis *RedisReconciler) GetSentinelServiceUrl(pods []corev1.Pod) string {
 		randomPod := pods[rand.Intn(len(pods))]
 		url = randomPod.Status.PodIP
 	} else {
-		url = fmt.Sprintf("%s-%s.svc", "rfs", redis.GetHarborClusterName())
+		url = fmt.Sprintf("%s-%s.svc", "rfs", redis.name)
 	}
 
 	return url
@@ -170,12 +170,12 @@ func (redis *RedisReconciler) GetHarborClusterNamespace() string {
 func (redis *RedisReconciler) GetRedisResource() corev1.ResourceList {
 	resources := corev1.ResourceList{}
 
-	if redis.HarborCluster.Spec.Redis.Spec.Server == nil {
+	if redis.spec.Server == nil {
 		return GenerateResourceList("1", "2Gi")
 	}
 
-	cpu := redis.HarborCluster.Spec.Redis.Spec.Server.Resources.Requests.Cpu()
-	mem := redis.HarborCluster.Spec.Redis.Spec.Server.Resources.Requests.Memory()
+	cpu := redis.spec.Server.Resources.Requests.Cpu()
+	mem := redis.spec.Server.Resources.Requests.Memory()
 
 	if cpu != nil {
 		resources[corev1.ResourceCPU] = *cpu
@@ -186,6 +186,34 @@ func (redis *RedisReconciler) GetRedisResource() corev1.ResourceList {
 	return resources
 }
 
+// GetRedisResources returns the resources of the redis servers, the limits default to the requests.
+func (redis *RedisReconciler) GetRedisResources() corev1.ResourceRequirements {
+	requests := redis.GetRedisResource()
+	limits := requests
+	if redis.spec.Server != nil && len(redis.spec.Server.Resources.Limits) > 0 {
+		limits = redis.spec.Server.Resources.Limits
+	}
+
+	return corev1.ResourceRequirements{
+		Requests: requests,
+		Limits:   limits,
+	}
+}
+
+// GetSentinelResources returns the resources of the sentinels, the resources of the redis servers if not set.
+func (redis *RedisReconciler) GetSentinelResources() corev1.ResourceRequirements {
+	sentinel := redis.spec.Sentinel
+	if sentinel == nil || (len(sentinel.Resources.Requests) == 0 && len(sentinel.Resources.Limits) == 0) {
+		return redis.GetRedisResources()
+	}
+
+	resources := *sentinel.Resources.DeepCopy()
+	if len(resources.Limits) == 0 {
+		resources.Limits = resources.Requests
+	}
+	return resources
+}
+
 // GenerateResourceList returns resource list
 func GenerateResourceList(cpu string, memory string) corev1.ResourceList {
 	resources := corev1.ResourceList{}
@@ -200,39 +228,39 @@ func GenerateResourceList(cpu string, memory string) corev1.ResourceList {
 
 // GetRedisServerReplica returns redis server replicas
 func (redis *RedisReconciler) GetRedisServerReplica() int32 {
-	if redis.HarborCluster.Spec.Redis.Spec.Server == nil {
+	if redis.spec.Server == nil {
 		return 3
 	}
 
-	if redis.HarborCluster.Spec.Redis.Spec.Server.Replicas == 0 {
+	if redis.spec.Server.Replicas == 0 {
 		return 3
 	}
-	return int32(redis.HarborCluster.Spec.Redis.Spec.Server.Replicas)
+	return int32(redis.spec.Server.Replicas)
 }
 
 // GetRedisSentinelReplica returns redis sentinel replicas
 func (redis *RedisReconciler) GetRedisSentinelReplica() int32 {
 
-	if redis.HarborCluster.Spec.Redis.Spec.Sentinel == nil {
+	if redis.spec.Sentinel == nil {
 		return 3
 	}
 
-	if redis.HarborCluster.Spec.Redis.Spec.Sentinel.Replicas == 0 {
+	if redis.spec.Sentinel.Replicas == 0 {
 		return 3
 	}
-	return int32(redis.HarborCluster.Spec.Redis.Spec.Sentinel.Replicas)
+	return int32(redis.spec.Sentinel.Replicas)
 }
 
 // GetRedisStorageSize returns redis server storage size
 func (redis *RedisReconciler) GetRedisStorageSize() string {
-	if redis.HarborCluster.Spec.Redis.Spec.Server == nil {
+	if redis.spec.Server == nil {
 		return "1Gi"
 	}
 
-	if redis.HarborCluster.Spec.Redis.Spec.Server.Storage == "" {
+	if redis.spec.Server.Storage == "" {
 		return "1Gi"
 	}
-	return redis.HarborCluster.Spec.Redis.Spec.Server.Storage
+	return redis.spec.Server.Storage
 }
 
 // GetPodsStatus returns deleting  and current pod list
@@ -258,44 +286,48 @@ func (redis *RedisReconciler) GetPodsStatus(podArray []corev1.Pod) ([]corev1.Pod
 	return deletingPods, currentPods
 }
 
-// GenRedisConnURL returns harbor component redis secret
-func (c *RedisConnect) GenRedisConnURL() string {
+// GenRedisConnURL returns harbor component redis secret url of the redis database
+func (c *RedisConnect) GenRedisConnURL(database int) string {
 	switch c.Schema {
 	case RedisSentinelSchema:
-		return c.genRedisSentinelConnURL()
+		return c.genRedisSentinelConnURL(database)
 	case RedisServerSchema:
-		return c.genRedisServerConnURL()
+		return c.genRedisServerConnURL(database)
 	default:
 		return ""
 	}
 }
 
 // genRedisSentinelConnURL returns redis sentinel connection url
-func (c *RedisConnect) genRedisSentinelConnURL() string {
+func (c *RedisConnect) genRedisSentinelConnURL(database int) string {
+
+	hostInfo := strings.Join(GenHostInfo(c.Endpoints, c.Port), ",")
+	return fmt.Sprintf("redis+sentinel://%s%s/%s/%d", c.genUserInfo(), hostInfo, c.GroupName, database)
+}
 
-	hostInfo := GenHostInfo(c.Endpoints, c.Port)
-	if c.Password != "" {
-		return fmt.Sprintf("redis+sentinel://:%s@%s/mymaster/0", c.Password, hostInfo)
+// genRedisServerConnURL returns redis server connection url, with the rediss scheme when TLS is enabled
+func (c *RedisConnect) genRedisServerConnURL(database int) string {
+	scheme := "redis"
+	if c.TLSConfig != nil {
+		scheme = "rediss"
 	}
 
-	return fmt.Sprintf("redis+sentinel://%s/mymaster/0", hostInfo)
+	hostInfo := strings.Join(GenHostInfo(c.Endpoints, c.Port), ",")
+	return fmt.Sprintf("%s://%s%s/%d", scheme, c.genUserInfo(), hostInfo, database)
 }
 
-// genRedisServerConnURL returns redis server connection url
-func (c *RedisConnect) genRedisServerConnURL() string {
-
-	hostInfo := GenHostInfo(c.Endpoints, c.Port)
-	if c.Password != "" {
-		return fmt.Sprintf("redis://:%s@%s/0", c.Password, hostInfo)
+// genUserInfo returns the user info of the connection url, with the ACL username if set.
+func (c *RedisConnect) genUserInfo() string {
+	if c.Password == "" {
+		return ""
 	}
-
-	return fmt.Sprintf("redis://%s/0", hostInfo)
+	return fmt.Sprintf("%s:%s@", c.Username, c.Password)
 }
 
 // GetRedisFailover returns RedisFailover object
 func (redis *RedisReconciler) GetRedisFailover() (*redisCli.RedisFailover, error) {
 	rf := &redisCli.RedisFailover{}
-	err := redis.Client.Get(types.NamespacedName{Name: redis.HarborCluster.Name, Namespace: redis.HarborCluster.Namespace}, rf)
+	err := redis.Client.Get(types.NamespacedName{Name: redis.name, Namespace: redis.HarborCluster.Namespace}, rf)
 	if err != nil {
 		return nil, err
 	}
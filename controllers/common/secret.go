@@ -0,0 +1,18 @@
+package common
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// IsSecretUpToDate check whether the data of the secret is the same as the desired string data.
+func IsSecretUpToDate(secret *corev1.Secret, stringData map[string]string) bool {
+	if len(secret.Data) != len(stringData) {
+		return false
+	}
+	for key, value := range stringData {
+		if current, ok := secret.Data[key]; !ok || string(current) != value {
+			return false
+		}
+	}
+	return true
+}
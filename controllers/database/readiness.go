@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	"github.com/goharbor/harbor-cluster-operator/controllers/common"
 	"github.com/goharbor/harbor-cluster-operator/controllers/k8s"
 	"github.com/goharbor/harbor-cluster-operator/lcm"
 	"github.com/jackc/pgx/v4"
@@ -107,7 +108,19 @@ func (postgres *PostgreSQLReconciler) DeployComponentSecret(conn *Connect, compo
 		}
 		return err
 	}
-	return nil
+
+	// the password changes when the database credentials are rotated
+	if common.IsSecretUpToDate(secret, sc.StringData) {
+		return nil
+	}
+
+	postgres.Log.Info("Updating Harbor Component Secret",
+		"namespace", postgres.HarborCluster.Namespace,
+		"name", secretName,
+		"component", component)
+	secret.Data = nil
+	secret.StringData = sc.StringData
+	return postgres.Client.Update(secret)
 }
 
 // GetExternalDatabaseInfo returns external database connection client
@@ -156,14 +169,17 @@ func (postgres *PostgreSQLReconciler) GetInClusterDatabaseInfo() (*Connect, *pgx
 		err     error
 	)
 
-	pw, err := postgres.GetInClusterDatabasePassword()
+	secret, err := postgres.getInClusterPasswordSecret()
 	if err != nil {
 		return connect, client, err
 	}
 
+	// harbor connects with its own login role once the credentials are rotated
+	username, pw := getHarborCredentials(secret)
 	if connect, err = postgres.GetInClusterDatabaseConn(postgres.GetDatabaseName(), pw); err != nil {
 		return connect, client, err
 	}
+	connect.Username = username
 
 	url := connect.GenDatabaseUrl()
 
@@ -0,0 +1,238 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	"github.com/goharbor/harbor-cluster-operator/controllers/common"
+	"github.com/jackc/pgx/v4"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// databasePendingPasswordKey keeps the new password in the password secret until the rotation is done,
+	// so that a failed rotation is resumed with the same password.
+	databasePendingPasswordKey = "pendingPassword"
+	databasePasswordLength     = 24
+
+	// the keys of the password secret which keep the login role of harbor once the credentials are rotated,
+	// and the role replaced by the last rotation until the harbor pods are restarted.
+	databaseHarborUsernameKey  = "harborUsername"
+	databaseHarborPasswordKey  = "harborPassword"
+	databaseRetiredUsernameKey = "retiredUsername"
+)
+
+// databaseRotationUsers are the login roles of harbor, used in turn by the rotations.
+// The roles are members of the postgres role and act as it, so that the objects created by harbor keep the same owner.
+var databaseRotationUsers = []string{"harbor_rotation_a", "harbor_rotation_b"}
+
+// RotateCredentials rotates the postgres credentials of harbor. Postgres keeps a single password per role,
+// so harbor connects with the other login role of databaseRotationUsers with a new password, and the replaced role
+// keeps working until the harbor pods are restarted, then it is disabled by RemoveRetiredCredentials.
+// It does:
+// - create the next login role if needed, and set its new password
+// - record the next role as the role of harbor in the password secret
+// The component secrets are updated by the next readiness check.
+// The credentials are not rotated again until the replaced role is disabled.
+func (postgres *PostgreSQLReconciler) RotateCredentials() error {
+	if postgres.HarborCluster.Spec.Database.Kind != goharborv1.InClusterComponent {
+		return nil
+	}
+
+	secret, err := postgres.getInClusterPasswordSecret()
+	if err != nil {
+		return err
+	}
+
+	if retired := string(secret.Data[databaseRetiredUsernameKey]); retired != "" {
+		postgres.Log.Info("Skip rotating the database credentials, the previous rotation is not finished", "namespace", postgres.HarborCluster.Namespace, "retiredUsername", retired)
+		return nil
+	}
+
+	username, _ := getHarborCredentials(secret)
+	nextUsername := getNextRotationUser(username)
+	newPassword, err := postgres.getPendingPassword(secret)
+	if err != nil {
+		return err
+	}
+
+	client, err := postgres.connectInCluster(secret)
+	if err != nil {
+		return err
+	}
+	defer client.Close(postgres.Ctx)
+
+	err = postgres.ensureRotationUser(client, nextUsername, newPassword)
+	if err != nil {
+		return err
+	}
+
+	postgres.Log.Info("Database credentials rotated", "namespace", postgres.HarborCluster.Namespace, "name", postgres.HarborCluster.Name, "username", nextUsername)
+
+	delete(secret.Data, databasePendingPasswordKey)
+	secret.Data[databaseHarborUsernameKey] = []byte(nextUsername)
+	secret.Data[databaseHarborPasswordKey] = []byte(newPassword)
+	secret.Data[databaseRetiredUsernameKey] = []byte(username)
+	secret.StringData = nil
+	return postgres.Client.Update(secret)
+}
+
+// ensureRotationUser creates the login role if it does not exist, and sets its password.
+func (postgres *PostgreSQLReconciler) ensureRotationUser(client *pgx.Conn, username, password string) error {
+	var exists bool
+	err := client.QueryRow(postgres.Ctx, "SELECT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = $1)", username).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	role := pgx.Identifier{username}.Sanitize()
+	owner := pgx.Identifier{InClusterDatabaseUserName}.Sanitize()
+	statements := []string{
+		fmt.Sprintf("ALTER ROLE %s WITH LOGIN PASSWORD %s", role, quoteLiteral(password)),
+		fmt.Sprintf("GRANT %s TO %s", owner, role),
+		fmt.Sprintf("ALTER ROLE %s SET role TO %s", role, owner),
+	}
+	if !exists {
+		statements = append([]string{fmt.Sprintf("CREATE ROLE %s", role)}, statements...)
+	}
+
+	for _, statement := range statements {
+		if _, err := client.Exec(postgres.Ctx, statement); err != nil {
+			return fmt.Errorf("set up database role %s: %w", username, err)
+		}
+	}
+	return nil
+}
+
+// RemoveRetiredCredentials disables the login role replaced by the last rotation, once the harbor pods are restarted.
+// The postgres role is used by the postgres operator too, so its password is rotated instead of being disabled.
+// It must be called after Reconcile reports the database ready.
+func (postgres *PostgreSQLReconciler) RemoveRetiredCredentials() error {
+	if postgres.HarborCluster.Spec.Database.Kind != goharborv1.InClusterComponent {
+		return nil
+	}
+
+	secret, err := postgres.getInClusterPasswordSecret()
+	if err != nil {
+		return err
+	}
+
+	retired := string(secret.Data[databaseRetiredUsernameKey])
+	if retired == "" {
+		return nil
+	}
+
+	if retired == InClusterDatabaseUserName {
+		return postgres.rotateSuperuserPassword(secret)
+	}
+
+	client, err := postgres.connectInCluster(secret)
+	if err != nil {
+		return err
+	}
+	defer client.Close(postgres.Ctx)
+
+	statement := fmt.Sprintf("ALTER ROLE %s WITH NOLOGIN PASSWORD NULL", pgx.Identifier{retired}.Sanitize())
+	if _, err := client.Exec(postgres.Ctx, statement); err != nil {
+		return fmt.Errorf("disable database role %s: %w", retired, err)
+	}
+
+	postgres.Log.Info("Retired database role disabled", "namespace", postgres.HarborCluster.Namespace, "username", retired)
+
+	delete(secret.Data, databaseRetiredUsernameKey)
+	return postgres.Client.Update(secret)
+}
+
+// rotateSuperuserPassword changes the password of the postgres role once harbor does not use it anymore,
+// and updates the password secret managed by the postgres operator.
+func (postgres *PostgreSQLReconciler) rotateSuperuserPassword(secret *corev1.Secret) error {
+	newPassword, err := postgres.getPendingPassword(secret)
+	if err != nil {
+		return err
+	}
+
+	client, err := postgres.connectInCluster(secret)
+	if err != nil {
+		return err
+	}
+	defer client.Close(postgres.Ctx)
+
+	statement := fmt.Sprintf("ALTER ROLE %s WITH PASSWORD %s", pgx.Identifier{InClusterDatabaseUserName}.Sanitize(), quoteLiteral(newPassword))
+	if _, err := client.Exec(postgres.Ctx, statement); err != nil {
+		return err
+	}
+
+	postgres.Log.Info("Database superuser password rotated", "namespace", postgres.HarborCluster.Namespace, "name", postgres.HarborCluster.Name)
+
+	delete(secret.Data, databasePendingPasswordKey)
+	delete(secret.Data, databaseRetiredUsernameKey)
+	secret.Data[InClusterDatabasePasswordKey] = []byte(newPassword)
+	return postgres.Client.Update(secret)
+}
+
+// getPendingPassword returns the pending password of the secret, a new one is generated and kept if there is none.
+func (postgres *PostgreSQLReconciler) getPendingPassword(secret *corev1.Secret) (string, error) {
+	if password := string(secret.Data[databasePendingPasswordKey]); password != "" {
+		return password, nil
+	}
+
+	password := common.RandomString(databasePasswordLength, "a")
+	secret.Data[databasePendingPasswordKey] = []byte(password)
+	return password, postgres.Client.Update(secret)
+}
+
+// connectInCluster connects to the inCluster database as the postgres role.
+func (postgres *PostgreSQLReconciler) connectInCluster(secret *corev1.Secret) (*pgx.Conn, error) {
+	conn, err := postgres.GetInClusterDatabaseConn(postgres.GetDatabaseName(), string(secret.Data[InClusterDatabasePasswordKey]))
+	if err != nil {
+		return nil, err
+	}
+	client, err := pgx.Connect(postgres.Ctx, conn.GenDatabaseUrl())
+	if err == nil {
+		return client, nil
+	}
+
+	pending := string(secret.Data[databasePendingPasswordKey])
+	if pending == "" {
+		return nil, err
+	}
+	// the password may have been changed by an interrupted rotation of the postgres role
+	conn.Password = pending
+	return pgx.Connect(postgres.Ctx, conn.GenDatabaseUrl())
+}
+
+func (postgres *PostgreSQLReconciler) getInClusterPasswordSecret() (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	secretName := GenInClusterPasswordSecretName(postgres.HarborCluster.Namespace, postgres.HarborCluster.Name)
+	err := postgres.Client.Get(types.NamespacedName{Name: secretName, Namespace: postgres.HarborCluster.Namespace}, secret)
+	if err != nil {
+		return nil, err
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	return secret, nil
+}
+
+// getHarborCredentials returns the login role of harbor and its password,
+// harbor connects as the postgres role until the credentials are rotated.
+func getHarborCredentials(secret *corev1.Secret) (string, string) {
+	if username := string(secret.Data[databaseHarborUsernameKey]); username != "" {
+		return username, string(secret.Data[databaseHarborPasswordKey])
+	}
+	return InClusterDatabaseUserName, string(secret.Data[InClusterDatabasePasswordKey])
+}
+
+// getNextRotationUser returns the login role of harbor after the rotation, which is not the current one.
+func getNextRotationUser(username string) string {
+	if username == databaseRotationUsers[0] {
+		return databaseRotationUsers[1]
+	}
+	return databaseRotationUsers[0]
+}
+
+func quoteLiteral(literal string) string {
+	return "'" + strings.ReplaceAll(literal, "'", "''") + "'"
+}
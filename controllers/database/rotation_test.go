@@ -0,0 +1,98 @@
+package database
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGetHarborCredentials(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         map[string][]byte
+		wantUsername string
+		wantPassword string
+	}{
+		{
+			name:         "never rotated",
+			data:         map[string][]byte{InClusterDatabasePasswordKey: []byte("superuser")},
+			wantUsername: InClusterDatabaseUserName,
+			wantPassword: "superuser",
+		},
+		{
+			name: "rotated",
+			data: map[string][]byte{
+				InClusterDatabasePasswordKey: []byte("superuser"),
+				databaseHarborUsernameKey:    []byte(databaseRotationUsers[0]),
+				databaseHarborPasswordKey:    []byte("harbor"),
+			},
+			wantUsername: databaseRotationUsers[0],
+			wantPassword: "harbor",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			username, password := getHarborCredentials(&corev1.Secret{Data: tt.data})
+			if username != tt.wantUsername || password != tt.wantPassword {
+				t.Errorf("getHarborCredentials() = %v, %v, want %v, %v", username, password, tt.wantUsername, tt.wantPassword)
+			}
+		})
+	}
+}
+
+func TestGetNextRotationUser(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		want     string
+	}{
+		{
+			name:     "first rotation",
+			username: InClusterDatabaseUserName,
+			want:     databaseRotationUsers[0],
+		},
+		{
+			name:     "second rotation",
+			username: databaseRotationUsers[0],
+			want:     databaseRotationUsers[1],
+		},
+		{
+			name:     "third rotation",
+			username: databaseRotationUsers[1],
+			want:     databaseRotationUsers[0],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getNextRotationUser(tt.username); got != tt.want {
+				t.Errorf("getNextRotationUser() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	tests := []struct {
+		name    string
+		literal string
+		want    string
+	}{
+		{
+			name:    "plain",
+			literal: "abc",
+			want:    "'abc'",
+		},
+		{
+			name:    "quotes are escaped",
+			literal: "a'b''c",
+			want:    "'a''b''''c'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quoteLiteral(tt.literal); got != tt.want {
+				t.Errorf("quoteLiteral() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
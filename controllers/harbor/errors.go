@@ -6,4 +6,6 @@ const (
 	ScaleHarborCRError       = "Scale harbor.goharbor.io CR error"
 	UpdateHarborCRError      = "Update harbor.goharbor.io CR error"
 	EmptyHarborCRStatusError = "Empty harbor.goharbor.io CR status error"
+	RestartHarborPodError    = "Restart harbor pod error"
+	RestartingHarborPods     = "Restarting harbor pods"
 )
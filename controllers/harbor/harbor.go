@@ -11,6 +11,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
@@ -27,6 +28,10 @@ type HarborReconciler struct {
 	DesiredHarborCR     *v1alpha1.Harbor
 	ImageGetter         image.ImageGetter
 	ComponentToCRStatus map[goharborv1.Component]*lcm.CRStatus
+
+	// CredentialsRotatedAt is the last rotation of the generated credentials,
+	// the pods of harbor components created before are restarted.
+	CredentialsRotatedAt *metav1.Time
 }
 
 // Reconciler implements the reconcile logic of services
@@ -52,6 +57,14 @@ func (harbor *HarborReconciler) Reconcile() (*lcm.CRStatus, error) {
 		return harbor.Update(harbor.HarborCluster)
 	}
 
+	restarting, err := harbor.restartStalePods()
+	if err != nil {
+		return harborClusterCRUnknownStatus(RestartHarborPodError, err.Error()), err
+	}
+	if restarting {
+		return harborClusterCRUnknownStatus(RestartingHarborPods, "Restarting harbor components with the rotated credentials."), nil
+	}
+
 	err = harbor.Get(harbor.getHarborCRNamespacedName(), &harborCR)
 	if err != nil {
 		return harborClusterCRUnknownStatus(GetHarborCRError, err.Error()), err
@@ -145,8 +158,12 @@ func harborClusterCRStatus(harbor *v1alpha1.Harbor) *lcm.CRStatus {
 }
 
 func (harbor *HarborReconciler) getHarborCRNamespacedName() types.NamespacedName {
+	return getHarborCRNamespacedName(harbor.HarborCluster)
+}
+
+func getHarborCRNamespacedName(harborCluster *goharborv1.HarborCluster) types.NamespacedName {
 	return types.NamespacedName{
-		Namespace: harbor.HarborCluster.Namespace,
-		Name:      fmt.Sprintf("%s-harbor", harbor.HarborCluster.Name),
+		Namespace: harborCluster.Namespace,
+		Name:      fmt.Sprintf("%s-harbor", harborCluster.Name),
 	}
 }
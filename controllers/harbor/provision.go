@@ -116,7 +116,7 @@ func (harbor *HarborReconciler) newChartMuseumComponentIfNecessary() *v1alpha1.C
 				NodeSelector:     nil,
 				ImagePullSecrets: harbor.getImagePullSecrets(),
 			},
-			StorageSecret: harbor.getStorageSecret(),
+			StorageSecret: harbor.getChartMuseumStorageSecret(),
 			CacheSecret:   harbor.getCacheSecret(lcm.ChartMuseumSecretForCache),
 		}
 	}
@@ -202,7 +202,8 @@ func (harbor *HarborReconciler) getStorageSecret() string {
 		name = lcm.GcsSecretForStorage
 	case "swift":
 		name = lcm.SwiftSecretForStorage
-	case "s3":
+	case "s3", "inCluster":
+		// the in-cluster minIO is provided as s3 storage
 		name = lcm.S3SecretForStorage
 	case "oss":
 		name = lcm.OssSecretForStorage
@@ -216,6 +217,28 @@ func (harbor *HarborReconciler) getStorageSecret() string {
 	return ""
 }
 
+// getChartMuseumStorageSecret will get a name of k8s secret which stores chartmuseum storage info.
+// The separate chartmuseum storage has its own secret. Otherwise the inCluster, s3 and oss storage have
+// a dedicated chartmuseum secret, other kinds share the registry one.
+func (harbor *HarborReconciler) getChartMuseumStorageSecret() string {
+	var name string
+	switch kind := harbor.HarborCluster.Spec.Storage.Kind; {
+	case harbor.HarborCluster.Spec.ChartMuseum.Storage != nil:
+		name = lcm.SeparateChartMuseumSecretForStorage
+	case kind == "inCluster":
+		name = lcm.InClusterChartMuseumSecretForStorage
+	case kind == "s3", kind == "oss":
+		name = lcm.ExternalChartMuseumSecretForStorage
+	default:
+		return harbor.getStorageSecret()
+	}
+	p := harbor.getProperty(goharborv1.ComponentStorage, name)
+	if p != nil {
+		return p.ToString()
+	}
+	return ""
+}
+
 func (harbor *HarborReconciler) getProperty(component goharborv1.Component, name string) *lcm.Property {
 	if harbor.ComponentToCRStatus == nil {
 		return nil
@@ -0,0 +1,143 @@
+package harbor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	"github.com/goharbor/harbor-cluster-operator/controllers/k8s"
+	"github.com/goharbor/harbor-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	AdminUsername = "admin"
+)
+
+// SetReadOnly switches the read-only mode of harbor by the configuration API of the core service.
+// In read-only mode, images can be pulled but not pushed or deleted.
+func SetReadOnly(ctx context.Context, client k8s.Client, harborCluster *goharborv1.HarborCluster, readOnly bool) error {
+	password, err := getAdminPassword(client, harborCluster)
+	if err != nil {
+		return err
+	}
+	return setReadOnly(ctx, getConfigurationsURL(harborCluster), password, readOnly)
+}
+
+// GetReadOnly returns whether harbor is in read-only mode, by the configuration API of the core service.
+func GetReadOnly(ctx context.Context, client k8s.Client, harborCluster *goharborv1.HarborCluster) (bool, error) {
+	password, err := getAdminPassword(client, harborCluster)
+	if err != nil {
+		return false, err
+	}
+	return getReadOnly(ctx, getConfigurationsURL(harborCluster), password)
+}
+
+func getAdminPassword(client k8s.Client, harborCluster *goharborv1.HarborCluster) (string, error) {
+	var secret corev1.Secret
+	err := client.Get(types.NamespacedName{Namespace: harborCluster.Namespace, Name: harborCluster.Spec.AdminPasswordSecret}, &secret)
+	if err != nil {
+		return "", err
+	}
+	return string(secret.Data[v1alpha1.HarborAdminPasswordKey]), nil
+}
+
+func setReadOnly(ctx context.Context, url, password string, readOnly bool) error {
+	body, err := json.Marshal(map[string]bool{"read_only": readOnly})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(AdminUsername, password)
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("set harbor read-only to %t: %s %s", readOnly, resp.Status, string(message))
+	}
+
+	return nil
+}
+
+func getReadOnly(ctx context.Context, url, password string) (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.SetBasicAuth(AdminUsername, password)
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return false, fmt.Errorf("get harbor read-only: %s %s", resp.Status, string(message))
+	}
+
+	// the configurations are returned with their values and whether they are editable
+	var configurations struct {
+		ReadOnly struct {
+			Value bool `json:"value"`
+		} `json:"read_only"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&configurations)
+	if err != nil {
+		return false, fmt.Errorf("get harbor read-only: %w", err)
+	}
+	return configurations.ReadOnly.Value, nil
+}
+
+// getConfigurationsURL returns the configuration API of the core service, which is versioned since harbor 2.0.
+func getConfigurationsURL(harborCluster *goharborv1.HarborCluster) string {
+	namespacedName := getHarborCRNamespacedName(harborCluster)
+	harborCR := &v1alpha1.Harbor{ObjectMeta: metav1.ObjectMeta{Name: namespacedName.Name}}
+	host := fmt.Sprintf("%s.%s", harborCR.NormalizeComponentName(v1alpha1.CoreName), namespacedName.Namespace)
+
+	if strings.HasPrefix(harborCluster.Spec.Version, "1.") {
+		return fmt.Sprintf("http://%s/api/configurations", host)
+	}
+	return fmt.Sprintf("http://%s/api/v2.0/configurations", host)
+}
+
+// IsStorageSecretApplied check whether the registry of harbor uses the storage secret and is ready.
+func IsStorageSecretApplied(client k8s.Client, harborCluster *goharborv1.HarborCluster, storageSecret string) (bool, error) {
+	var harborCR v1alpha1.Harbor
+	err := client.Get(getHarborCRNamespacedName(harborCluster), &harborCR)
+	if err != nil {
+		return false, err
+	}
+
+	if harborCR.Spec.Components.Registry == nil || harborCR.Spec.Components.Registry.StorageSecret != storageSecret {
+		return false, nil
+	}
+	if harborCR.Status.ObservedGeneration != harborCR.Generation {
+		return false, nil
+	}
+
+	for _, condition := range harborCR.Status.Conditions {
+		if condition.Type == v1alpha1.ReadyConditionType {
+			return condition.Status == corev1.ConditionTrue, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,106 @@
+package harbor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newFakeCoreServer is a local stand-in of the configuration API of the harbor core service.
+func newFakeCoreServer(password string, readOnly *bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, pw, ok := r.BasicAuth()
+		if !ok || username != AdminUsername || pw != password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"read_only":                    map[string]interface{}{"value": *readOnly, "editable": true},
+				"auth_mode":                    map[string]interface{}{"value": "db_auth", "editable": false},
+				"project_creation_restriction": map[string]interface{}{"value": "everyone", "editable": true},
+			})
+		case http.MethodPut:
+			var body map[string]bool
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			*readOnly = body["read_only"]
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+}
+
+func TestReadOnly(t *testing.T) {
+	tests := []struct {
+		name     string
+		readOnly bool
+		set      bool
+	}{
+		{
+			name:     "put writable harbor into read-only mode",
+			readOnly: false,
+			set:      true,
+		},
+		{
+			name:     "restore read-only harbor",
+			readOnly: true,
+			set:      true,
+		},
+		{
+			name:     "leave read-only mode",
+			readOnly: true,
+			set:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readOnly := tt.readOnly
+			server := newFakeCoreServer("Harbor12345", &readOnly)
+			defer server.Close()
+			ctx := context.Background()
+
+			got, err := getReadOnly(ctx, server.URL, "Harbor12345")
+			if err != nil {
+				t.Fatalf("getReadOnly() error = %v", err)
+			}
+			if got != tt.readOnly {
+				t.Errorf("getReadOnly() = %v, want %v", got, tt.readOnly)
+			}
+
+			if err := setReadOnly(ctx, server.URL, "Harbor12345", tt.set); err != nil {
+				t.Fatalf("setReadOnly() error = %v", err)
+			}
+			got, err = getReadOnly(ctx, server.URL, "Harbor12345")
+			if err != nil {
+				t.Fatalf("getReadOnly() error = %v", err)
+			}
+			if got != tt.set {
+				t.Errorf("getReadOnly() after setReadOnly() = %v, want %v", got, tt.set)
+			}
+		})
+	}
+}
+
+func TestReadOnlyUnauthorized(t *testing.T) {
+	readOnly := false
+	server := newFakeCoreServer("Harbor12345", &readOnly)
+	defer server.Close()
+	ctx := context.Background()
+
+	if _, err := getReadOnly(ctx, server.URL, "wrong"); err == nil {
+		t.Errorf("getReadOnly() error = nil, want an error")
+	}
+	if err := setReadOnly(ctx, server.URL, "wrong", true); err == nil {
+		t.Errorf("setReadOnly() error = nil, want an error")
+	}
+	if readOnly {
+		t.Errorf("setReadOnly() changed the mode without authentication")
+	}
+}
@@ -0,0 +1,98 @@
+package harbor
+
+import (
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// credentialsRotatedAtAnnotation is set on the pod template of the harbor deployments to roll them out
+// with the rotated credentials.
+const credentialsRotatedAtAnnotation = "goharbor.io/credentials-rotated-at"
+
+// restartStalePods restarts the pods of harbor components which were created before the credentials were rotated,
+// so that they load the rotated credentials.
+// The deployments are rolled out by an annotation of the pod template, the rolling update surges a new pod before
+// an old one is removed, so that the components keep serving during the restart, even with a single replica.
+// It returns true until every stale pod is gone, the replaced credentials are kept valid until then.
+func (harbor *HarborReconciler) restartStalePods() (bool, error) {
+	if harbor.CredentialsRotatedAt == nil {
+		return false, nil
+	}
+
+	var deployments appsv1.DeploymentList
+	err := harbor.List(&client.ListOptions{Namespace: harbor.HarborCluster.Namespace}, &deployments)
+	if err != nil {
+		return false, err
+	}
+
+	restarting := false
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		if !metav1.IsControlledBy(deployment, harbor.CurrentHarborCR) {
+			continue
+		}
+
+		stalePods, err := harbor.getStalePods(deployment)
+		if err != nil {
+			return false, err
+		}
+		if len(stalePods) == 0 {
+			continue
+		}
+		restarting = true
+
+		if !setRestartAnnotation(deployment, harbor.CredentialsRotatedAt.UTC().Format(time.RFC3339)) {
+			continue
+		}
+
+		err = harbor.Client.Update(deployment)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return restarting, nil
+}
+
+// setRestartAnnotation sets the restart annotation of the pod template, it returns false if it is already set.
+func setRestartAnnotation(deployment *appsv1.Deployment, rotatedAt string) bool {
+	if deployment.Spec.Template.Annotations[credentialsRotatedAtAnnotation] == rotatedAt {
+		return false
+	}
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = map[string]string{}
+	}
+	deployment.Spec.Template.Annotations[credentialsRotatedAtAnnotation] = rotatedAt
+	return true
+}
+
+// getStalePods returns the pods of the deployment created before the credentials were rotated,
+// the terminating pods are included as they may still use the replaced credentials.
+func (harbor *HarborReconciler) getStalePods(deployment *appsv1.Deployment) ([]corev1.Pod, error) {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var pods corev1.PodList
+	err = harbor.List(&client.ListOptions{Namespace: deployment.Namespace, LabelSelector: selector}, &pods)
+	if err != nil {
+		return nil, err
+	}
+
+	var stalePods []corev1.Pod
+	for _, pod := range pods.Items {
+		if isStalePod(&pod, harbor.CredentialsRotatedAt) {
+			stalePods = append(stalePods, pod)
+		}
+	}
+	return stalePods, nil
+}
+
+func isStalePod(pod *corev1.Pod, rotatedAt *metav1.Time) bool {
+	return pod.CreationTimestamp.Before(rotatedAt)
+}
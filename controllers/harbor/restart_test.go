@@ -0,0 +1,102 @@
+package harbor
+
+import (
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetRestartAnnotation(t *testing.T) {
+	rotatedAt := "2020-06-01T10:00:00Z"
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{
+		{
+			name: "no annotation",
+			want: true,
+		},
+		{
+			name:        "other annotations are kept",
+			annotations: map[string]string{"foo": "bar"},
+			want:        true,
+		},
+		{
+			name:        "previous rotation",
+			annotations: map[string]string{credentialsRotatedAtAnnotation: "2020-05-01T10:00:00Z"},
+			want:        true,
+		},
+		{
+			name:        "already restarting",
+			annotations: map[string]string{credentialsRotatedAtAnnotation: rotatedAt},
+			want:        false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Template.Annotations = tt.annotations
+			others := len(tt.annotations)
+			if _, ok := tt.annotations[credentialsRotatedAtAnnotation]; ok {
+				others--
+			}
+
+			if got := setRestartAnnotation(deployment, rotatedAt); got != tt.want {
+				t.Errorf("setRestartAnnotation() = %v, want %v", got, tt.want)
+			}
+			if got := deployment.Spec.Template.Annotations[credentialsRotatedAtAnnotation]; got != rotatedAt {
+				t.Errorf("setRestartAnnotation() annotation = %q, want %q", got, rotatedAt)
+			}
+			if got := len(deployment.Spec.Template.Annotations) - 1; got != others {
+				t.Errorf("setRestartAnnotation() other annotations = %d, want %d", got, others)
+			}
+		})
+	}
+}
+
+func TestIsStalePod(t *testing.T) {
+	rotatedAt := metav1.NewTime(time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC))
+	deletedAt := metav1.NewTime(rotatedAt.Add(time.Minute))
+
+	tests := []struct {
+		name string
+		pod  corev1.Pod
+		want bool
+	}{
+		{
+			name: "created before the rotation",
+			pod:  corev1.Pod{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(rotatedAt.Add(-time.Hour))}},
+			want: true,
+		},
+		{
+			name: "terminating pod keeps the replaced credentials",
+			pod: corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				CreationTimestamp: metav1.NewTime(rotatedAt.Add(-time.Hour)),
+				DeletionTimestamp: &deletedAt,
+			}},
+			want: true,
+		},
+		{
+			name: "created at the rotation",
+			pod:  corev1.Pod{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: rotatedAt}},
+			want: false,
+		},
+		{
+			name: "created after the rotation",
+			pod:  corev1.Pod{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(rotatedAt.Add(time.Minute))}},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isStalePod(&tt.pod, &rotatedAt); got != tt.want {
+				t.Errorf("isStalePod() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
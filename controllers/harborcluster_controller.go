@@ -17,12 +17,19 @@ package controllers
 
 import (
 	"context"
+	"github.com/goharbor/harbor-cluster-operator/controllers/cache"
+	"github.com/goharbor/harbor-cluster-operator/controllers/harbor"
 	"github.com/goharbor/harbor-cluster-operator/controllers/image"
 	"github.com/goharbor/harbor-cluster-operator/controllers/k8s"
+	"github.com/goharbor/harbor-cluster-operator/controllers/storage"
 	"github.com/goharbor/harbor-cluster-operator/lcm"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -41,6 +48,9 @@ type HarborClusterReconciler struct {
 	Scheme       *runtime.Scheme
 	RequeueAfter time.Duration
 	Recorder     record.EventRecorder
+
+	// AllowedSecretNamespaces are the namespaces in which secrets can be referenced across namespaces.
+	AllowedSecretNamespaces []string
 }
 
 // +kubebuilder:rbac:groups=goharbor.io,resources=harborclusters,verbs=get;list;watch;create;update;patch;delete
@@ -48,9 +58,12 @@ type HarborClusterReconciler struct {
 // +kubebuilder:rbac:groups=goharbor.io,resources=harbors,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=databases.spotahome.com,resources=redisfailovers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=acid.zalan.do,resources=postgresqls,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=operator.min.io,resources=minioinstances,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;update
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;delete
 // +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list
-// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
-// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;delete
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;update
 
 func (r *HarborClusterReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
@@ -82,21 +95,26 @@ func (r *HarborClusterReconciler) Reconcile(req ctrl.Request) (ctrl.Result, erro
 		Log:      r.Log,
 		DClient:  k8s.WrapDClient(dClient),
 		Scheme:   r.Scheme,
+
+		AllowedSecretNamespaces: r.AllowedSecretNamespaces,
 	}
 
-	cacheStatus, err := r.Cache(ctx, &harborCluster, option).Reconcile()
+	cacheReconciler := r.Cache(ctx, &harborCluster, option)
+	cacheStatus, err := cacheReconciler.Reconcile()
 	if err != nil {
 		log.Error(err, "error when reconcile cache component.")
 		return ctrl.Result{}, err
 	}
 
-	dbStatus, err := r.Database(ctx, &harborCluster, option).Reconcile()
+	dbReconciler := r.Database(ctx, &harborCluster, option)
+	dbStatus, err := dbReconciler.Reconcile()
 	if err != nil {
 		log.Error(err, "error when reconcile database component.")
 		return ctrl.Result{}, err
 	}
 
-	storageStatus, err := r.Storage(ctx, &harborCluster, option).Reconcile()
+	storageReconciler := r.Storage(ctx, &harborCluster, option)
+	storageStatus, err := storageReconciler.Reconcile()
 	if err != nil {
 		log.Error(err, "error when reconcile storage component.")
 		return ctrl.Result{}, err
@@ -119,6 +137,16 @@ func (r *HarborClusterReconciler) Reconcile(req ctrl.Request) (ctrl.Result, erro
 		}, err
 	}
 
+	if isCredentialRotationDue(&harborCluster, time.Now()) {
+		log.Info("rotate credentials.")
+		if err := r.rotateCredentials(ctx, &harborCluster, cacheReconciler, dbReconciler, storageReconciler); err != nil {
+			log.Error(err, "error when rotate credentials.")
+			return ctrl.Result{}, err
+		}
+		// requeue to update the component secrets with the rotated credentials
+		return ctrl.Result{Requeue: true}, nil
+	}
+
 	getRegistry := func() *string {
 		if harborCluster.Spec.ImageSource != nil && harborCluster.Spec.ImageSource.Registry != "" {
 			return &harborCluster.Spec.ImageSource.Registry
@@ -144,6 +172,36 @@ func (r *HarborClusterReconciler) Reconcile(req ctrl.Request) (ctrl.Result, erro
 		return ctrl.Result{}, err
 	}
 
+	// wait for the harbor deployments to be rolled out with the rotated credentials
+	if harborStatus.Condition.Reason == harbor.RestartingHarborPods {
+		return ctrl.Result{
+			Requeue:      true,
+			RequeueAfter: time.Second * r.RequeueAfter,
+		}, nil
+	}
+
+	// the replaced credentials are only removed once every harbor pod is restarted with the rotated ones
+	if r.ComponentsAreAllReady(componentToStatus) {
+		if err := removeRetiredCredentials(cacheReconciler, dbReconciler, storageReconciler); err != nil {
+			log.Error(err, "error when remove retired credentials.")
+			return ctrl.Result{}, err
+		}
+	}
+
+	requeueAfter, ok := getCredentialRotationRequeue(&harborCluster, time.Now())
+	if usageRequeueAfter, usageOk := storage.GetUsageReportRequeue(&harborCluster, time.Now()); usageOk && (!ok || usageRequeueAfter < requeueAfter) {
+		requeueAfter, ok = usageRequeueAfter, true
+	}
+	if replicationRequeueAfter, replicationOk := storage.GetReplicationRequeue(&harborCluster, time.Now()); replicationOk && (!ok || replicationRequeueAfter < requeueAfter) {
+		requeueAfter, ok = replicationRequeueAfter, true
+	}
+	if aclRequeueAfter, aclOk := cache.GetACLUsersRequeue(&harborCluster); aclOk && (!ok || aclRequeueAfter < requeueAfter) {
+		requeueAfter, ok = aclRequeueAfter, true
+	}
+	if ok {
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
 	return ctrl.Result{}, nil
 }
 
@@ -211,8 +269,47 @@ func (r *HarborClusterReconciler) getHarborClusterCondition(
 	}, true
 }
 
+// secretToHarborClusters maps a secret to the HarborClusters whose storage references it,
+// so that the storage secret is re-rendered when the referenced secret changes.
+func (r *HarborClusterReconciler) secretToHarborClusters(object handler.MapObject) []reconcile.Request {
+	var requests []reconcile.Request
+
+	namespace := object.Meta.GetNamespace()
+	listOptions := []client.ListOption{}
+	if !storage.IsSecretNamespaceAllowed(namespace, r.AllowedSecretNamespaces) {
+		listOptions = append(listOptions, client.InNamespace(namespace))
+	}
+
+	var harborClusters goharborv1.HarborClusterList
+	if err := r.List(context.Background(), &harborClusters, listOptions...); err != nil {
+		r.Log.Error(err, "unable to list HarborClusters", "secret", namespace+"/"+object.Meta.GetName())
+		return requests
+	}
+
+	secret := types.NamespacedName{
+		Namespace: namespace,
+		Name:      object.Meta.GetName(),
+	}
+	for i := range harborClusters.Items {
+		harborCluster := &harborClusters.Items[i]
+		if storage.IsSecretReferenced(harborCluster, secret) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: harborCluster.Namespace,
+					Name:      harborCluster.Name,
+				},
+			})
+		}
+	}
+
+	return requests
+}
+
 func (r *HarborClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&goharborv1.HarborCluster{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.secretToHarborClusters),
+		}).
 		Complete(r)
 }
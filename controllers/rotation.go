@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	CredentialsRotatedEvent = "CredentialsRotated"
+)
+
+// CredentialRotator rotates the credentials generated for the dependent service,
+// it does nothing if the service is not provisioned by the operator.
+type CredentialRotator interface {
+	RotateCredentials() error
+}
+
+// RetiredCredentialRemover removes the credentials replaced by the rotations,
+// which are kept valid until the harbor pods are restarted with the rotated credentials.
+type RetiredCredentialRemover interface {
+	RemoveRetiredCredentials() error
+}
+
+// isCredentialRotationDue check whether a rotation is requested by the annotation,
+// or the rotation interval has elapsed since the last rotation.
+func isCredentialRotationDue(harborCluster *goharborv1.HarborCluster, now time.Time) bool {
+	lastRotationTime, lastRequest := getLastCredentialRotation(harborCluster)
+
+	if request, ok := harborCluster.Annotations[goharborv1.CredentialRotationAnnotation]; ok && request != lastRequest {
+		return true
+	}
+
+	next, ok := getNextCredentialRotation(harborCluster, lastRotationTime)
+	return ok && !now.Before(next)
+}
+
+// getNextCredentialRotation returns the time of the next scheduled rotation, if an interval is set.
+func getNextCredentialRotation(harborCluster *goharborv1.HarborCluster, lastRotationTime time.Time) (time.Time, bool) {
+	rotation := harborCluster.Spec.CredentialRotation
+	if rotation == nil || rotation.Interval.Duration <= 0 {
+		return time.Time{}, false
+	}
+	return lastRotationTime.Add(rotation.Interval.Duration), true
+}
+
+// getLastCredentialRotation returns the time and the request of the last rotation,
+// the credentials are generated when the harbor cluster is created if they are never rotated.
+func getLastCredentialRotation(harborCluster *goharborv1.HarborCluster) (time.Time, string) {
+	if harborCluster.Status.CredentialRotation == nil {
+		return harborCluster.CreationTimestamp.Time, ""
+	}
+	return harborCluster.Status.CredentialRotation.LastRotationTime.Time, harborCluster.Status.CredentialRotation.LastRequest
+}
+
+// getCredentialRotationRequeue returns the duration until the next scheduled rotation.
+func getCredentialRotationRequeue(harborCluster *goharborv1.HarborCluster, now time.Time) (time.Duration, bool) {
+	lastRotationTime, _ := getLastCredentialRotation(harborCluster)
+	next, ok := getNextCredentialRotation(harborCluster, lastRotationTime)
+	if !ok {
+		return 0, false
+	}
+	return next.Sub(now), true
+}
+
+// rotateCredentials rotates the credentials of the dependent services, and records the rotation in status.
+// The component secrets are updated by the next reconciliation, then the harbor pods are restarted.
+func (r *HarborClusterReconciler) rotateCredentials(ctx context.Context, harborCluster *goharborv1.HarborCluster, reconcilers ...Reconciler) error {
+	for _, reconciler := range reconcilers {
+		rotator, ok := reconciler.(CredentialRotator)
+		if !ok {
+			continue
+		}
+		if err := rotator.RotateCredentials(); err != nil {
+			return err
+		}
+	}
+
+	harborCluster.Status.CredentialRotation = &goharborv1.CredentialRotationStatus{
+		LastRotationTime: metav1.Now(),
+		LastRequest:      harborCluster.Annotations[goharborv1.CredentialRotationAnnotation],
+	}
+	r.Recorder.Event(harborCluster, corev1.EventTypeNormal, CredentialsRotatedEvent, "The generated credentials are rotated.")
+
+	return r.Update(ctx, harborCluster)
+}
+
+// removeRetiredCredentials removes the credentials replaced by the rotations, it must be called once harbor is ready
+// and no harbor pod is left with the replaced credentials.
+func removeRetiredCredentials(reconcilers ...Reconciler) error {
+	for _, reconciler := range reconcilers {
+		remover, ok := reconciler.(RetiredCredentialRemover)
+		if !ok {
+			continue
+		}
+		if err := remover.RemoveRetiredCredentials(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/goharbor/harbor-cluster-operator/controllers/k8s"
 	"github.com/goharbor/harbor-cluster-operator/controllers/storage"
 	"github.com/goharbor/harbor-cluster-operator/lcm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
 )
@@ -41,6 +42,9 @@ type GetOptions struct {
 	DClient     k8s.DClient
 	Scheme      *runtime.Scheme
 	ImageGetter image.ImageGetter
+
+	// AllowedSecretNamespaces are the namespaces in which secrets can be referenced across namespaces.
+	AllowedSecretNamespaces []string
 }
 
 type ServiceGetterImpl struct {
@@ -77,15 +81,23 @@ func (impl *ServiceGetterImpl) Storage(ctx context.Context, harborCluster *gohar
 		Ctx:           ctx,
 		Log:           options.Log,
 		Recorder:      options.Recorder,
+
+		AllowedSecretNamespaces: options.AllowedSecretNamespaces,
 	}
 }
 
 func (impl *ServiceGetterImpl) Harbor(ctx context.Context, harborCluster *goharborv1.HarborCluster, componentToCRStatus map[goharborv1.Component]*lcm.CRStatus, options *GetOptions) Reconciler {
+	var credentialsRotatedAt *metav1.Time
+	if harborCluster.Status.CredentialRotation != nil {
+		credentialsRotatedAt = &harborCluster.Status.CredentialRotation.LastRotationTime
+	}
+
 	return &harbor.HarborReconciler{
-		HarborCluster:       harborCluster,
-		Client:              options.Client,
-		ImageGetter:         options.ImageGetter,
-		Ctx:                 ctx,
-		ComponentToCRStatus: componentToCRStatus,
+		HarborCluster:        harborCluster,
+		Client:               options.Client,
+		ImageGetter:          options.ImageGetter,
+		Ctx:                  ctx,
+		ComponentToCRStatus:  componentToCRStatus,
+		CredentialsRotatedAt: credentialsRotatedAt,
 	}
 }
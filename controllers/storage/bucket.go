@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"path"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	minv6 "github.com/minio/minio-go/v6"
+)
+
+// getCreateBucket returns the settings of the bucket created by the operator,
+// it is nil if the bucket of the s3 or oss storage is provisioned by the user.
+func (m *MinIOReconciler) getCreateBucket() *goharborv1.CreateBucket {
+	storage := m.HarborCluster.Spec.Storage
+	switch storage.Kind {
+	case s3Storage:
+		if storage.S3 != nil {
+			return storage.S3.CreateBucket
+		}
+	case ossStorage:
+		if storage.Oss != nil {
+			return storage.Oss.CreateBucket
+		}
+	}
+	return nil
+}
+
+// getExternalRootDirectory returns the root directory in the bucket of the s3 or oss storage.
+// If the bucket is created by the operator, it defaults to a directory of the harbor cluster,
+// so that the harbor clusters sharing the bucket do not overwrite the data of each other.
+func (m *MinIOReconciler) getExternalRootDirectory(rootDirectory string) string {
+	if rootDirectory != "" || m.getCreateBucket() == nil {
+		return rootDirectory
+	}
+	return path.Join("/", m.HarborCluster.Namespace, m.HarborCluster.Name)
+}
+
+// ensureExternalBucket creates the bucket of the s3 or oss storage if it does not exist and createBucket is set.
+// The versioning and the default encryption are applied only to the created bucket, an existing bucket is left unchanged.
+func (m *MinIOReconciler) ensureExternalBucket() error {
+	createBucket := m.getCreateBucket()
+	if createBucket == nil {
+		return nil
+	}
+
+	target, err := m.getS3Target()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(m.Ctx, probeTimeout)
+	defer cancel()
+
+	// the S3 client retries until the context expires if the endpoint is not reachable.
+	err = probeEndpoint(ctx, target.getURL(), false)
+	if err != nil {
+		return err
+	}
+
+	client, err := target.newClient()
+	if err != nil {
+		return err
+	}
+
+	exists, err := client.BucketExistsWithContext(ctx, target.Bucket)
+	if err != nil || exists {
+		return err
+	}
+
+	m.Log.Info("Creating external bucket", "bucket", target.Bucket, "region", target.Region)
+	err = client.MakeBucketWithContext(ctx, target.Bucket, target.Region)
+	if err != nil {
+		return err
+	}
+
+	err = configureBucket(ctx, client, target.Bucket, createBucket)
+	if err != nil {
+		// the bucket is still empty, it is removed to be created again with the settings at the next reconcile.
+		if removeErr := client.RemoveBucket(target.Bucket); removeErr != nil {
+			m.Log.Error(removeErr, "Failed to remove the external bucket", "bucket", target.Bucket)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func configureBucket(ctx context.Context, client *minv6.Client, bucket string, createBucket *goharborv1.CreateBucket) error {
+	if createBucket.Versioning {
+		err := client.EnableVersioningWithContext(ctx, bucket)
+		if err != nil {
+			return err
+		}
+	}
+
+	if createBucket.Encryption != "" {
+		err := client.SetBucketEncryptionWithContext(ctx, bucket, minv6.ServerSideEncryptionConfiguration{
+			Rules: []minv6.Rule{
+				{
+					Apply: minv6.ApplyServerSideEncryptionByDefault{
+						SSEAlgorithm:   createBucket.Encryption,
+						KmsMasterKeyID: createBucket.KMSKeyID,
+					},
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
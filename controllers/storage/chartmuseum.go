@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	"github.com/goharbor/harbor-cluster-operator/lcm"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	DefaultChartMuseumStorageSuffix = "harbor-cluster-chartmuseum-storage"
+
+	// chartMuseumMicrosoftStorageKind is the chartmuseum storage backend of azure.
+	chartMuseumMicrosoftStorageKind = "microsoft"
+)
+
+// getChartMuseumStorage returns the storage of chartmuseum separate from the registry, if it is set.
+func (m *MinIOReconciler) getChartMuseumStorage() *goharborv1.ChartMuseumStorage {
+	if m.HarborCluster.Spec.ChartMuseum == nil {
+		return nil
+	}
+	return m.HarborCluster.Spec.ChartMuseum.Storage
+}
+
+// isChartMuseumStoredWithRegistry check whether chartmuseum is enabled without a separate storage.
+func (m *MinIOReconciler) isChartMuseumStoredWithRegistry() bool {
+	return m.HarborCluster.Spec.ChartMuseum != nil && m.HarborCluster.Spec.ChartMuseum.Storage == nil
+}
+
+// reconcileChartMuseumStorage adds the secret of the separate chartmuseum storage to the properties of the ready storage.
+// The storage is not ready until the chartmuseum storage passes the checks too.
+func (m *MinIOReconciler) reconcileChartMuseumStorage(status *lcm.CRStatus) (*lcm.CRStatus, error) {
+	if m.getChartMuseumStorage() == nil || status.Condition.Status != corev1.ConditionTrue {
+		return status, nil
+	}
+
+	secret, err := m.generateChartMuseumStorageSecret()
+	if err != nil {
+		return minioNotReadyStatus(GetExternalCredentialError, err.Error()), err
+	}
+	err = m.applySecret(secret)
+	if err != nil {
+		return minioNotReadyStatus(CreateExternalSecretError, err.Error()), err
+	}
+
+	err = m.probeChartMuseumStorage()
+	if err != nil {
+		reason := getProbeReason(err)
+		m.Log.Info("Chartmuseum storage check failed", "reason", reason, "error", err.Error())
+		return minioNotReadyStatus(reason, "chartmuseum storage: "+err.Error()), nil
+	}
+
+	status.Properties.Add(lcm.SeparateChartMuseumSecretForStorage, secret.Name)
+	return status, nil
+}
+
+// getChartMuseumS3Target returns the bucket of the s3 or oss chartmuseum storage, and the credentials to access it.
+func (m *MinIOReconciler) getChartMuseumS3Target() (*s3Target, error) {
+	storage := m.getChartMuseumStorage()
+	return m.getS3TargetOf(storage.Kind, storage.S3, storage.Oss)
+}
+
+// generateChartMuseumStorageSecret returns the secret of the separate chartmuseum storage,
+// the keys are loaded as environment variables with and without the STORAGE_ prefix.
+func (m *MinIOReconciler) generateChartMuseumStorageSecret() (*corev1.Secret, error) {
+	storage := m.getChartMuseumStorage()
+
+	var data map[string][]byte
+	switch storage.Kind {
+	case s3Storage, ossStorage:
+		target, err := m.getChartMuseumS3Target()
+		if err != nil {
+			return nil, err
+		}
+		data = getChartMuseumS3Data(storage.Kind, target, target.getObjectKey(""), storage.Kind == s3Storage && storage.S3.RegionEndpoint != "")
+	case azureStorage:
+		accountKey, err := m.getCredential(storage.Azure.AccountKey, storage.Azure.AccountKeyRef)
+		if err != nil {
+			return nil, err
+		}
+		data = map[string][]byte{
+			"kind":                     []byte(chartMuseumMicrosoftStorageKind),
+			"MICROSOFT_CONTAINER":      []byte(storage.Azure.Container),
+			"AZURE_STORAGE_ACCOUNT":    []byte(storage.Azure.AccountName),
+			"AZURE_STORAGE_ACCESS_KEY": []byte(accountKey),
+		}
+	default:
+		return nil, fmt.Errorf(NotSupportType)
+	}
+
+	labels := m.getLabels()
+	labels[LabelOfStorageType] = storage.Kind
+
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        m.HarborCluster.Name + "-" + DefaultChartMuseumStorageSuffix,
+			Namespace:   m.HarborCluster.Namespace,
+			Labels:      labels,
+			Annotations: m.generateAnnotations(),
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(m.HarborCluster, goharborv1.HarborClusterGVK),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}, nil
+}
+
+// probeChartMuseumStorage checks the chartmuseum storage as the external storage of the same kind.
+func (m *MinIOReconciler) probeChartMuseumStorage() error {
+	ctx, cancel := context.WithTimeout(m.Ctx, probeTimeout)
+	defer cancel()
+
+	storage := m.getChartMuseumStorage()
+	switch storage.Kind {
+	case s3Storage, ossStorage:
+		target, err := m.getChartMuseumS3Target()
+		if err != nil {
+			return &storageProbeError{Reason: GetExternalCredentialError, Err: err}
+		}
+		return m.probeS3Target(ctx, target)
+	case azureStorage:
+		return probeEndpoint(ctx, getAzureEndpointOf(storage.Azure), false)
+	default:
+		return &storageProbeError{Reason: NotSupportType, Err: fmt.Errorf(NotSupportType)}
+	}
+}
@@ -1,18 +1,47 @@
 package storage
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
 	minv6 "github.com/minio/minio-go/v6"
+	iampolicy "github.com/minio/minio/pkg/iam/policy"
+	"github.com/minio/minio/pkg/madmin"
 	"log"
+	"net/http"
 )
 
 type Minio interface {
 	IsBucketExists(bucket string) (bool, error)
 	CreateBucket(bucket string) error
+	// AddUser creates the user, or updates the secret key of the existing user.
+	AddUser(accessKey, secretKey string) error
+	// RemoveUser removes the user, it is not an error if the user does not exist.
+	RemoveUser(accessKey string) error
+	// AddBucketPolicy creates or updates the policy which is scoped to the object access of the buckets,
+	// the bucket configuration is left to the root user.
+	AddBucketPolicy(policyName string, buckets ...string) error
+	// SetUserPolicy attaches the policy to the user.
+	SetUserPolicy(policyName, accessKey string) error
+	// SetBucketEncryption encrypts the new objects of the bucket with SSE-S3 by default.
+	SetBucketEncryption(bucket string) error
+	// ServerInfo returns the state of the servers and of their drives.
+	ServerInfo(ctx context.Context) (madmin.InfoMessage, error)
+	// StorageInfo returns the backend of minIO, and the state of the drives of every erasure set.
+	StorageInfo(ctx context.Context) (madmin.StorageInfo, error)
+	// BackgroundHealStatus returns the status of the background healing.
+	BackgroundHealStatus(ctx context.Context) (madmin.BgHealState, error)
+	// DataUsageInfo returns the usage of the buckets collected by the data usage crawler.
+	DataUsageInfo(ctx context.Context) (madmin.DataUsageInfo, error)
 }
 
 type MinioClient struct {
-	Client   *minv6.Client
-	Location string
+	Client      *minv6.Client
+	AdminClient *madmin.AdminClient
+	Location    string
 }
 
 func GetMinioClient(endpoint, accessKeyID, secretAccessKey, location string, useSSL bool) (*MinioClient, error) {
@@ -23,12 +52,33 @@ func GetMinioClient(endpoint, accessKeyID, secretAccessKey, location string, use
 		return minioClient, err
 	}
 
+	adminClient, err := madmin.New(endpoint, accessKeyID, secretAccessKey, useSSL)
+	if err != nil {
+		return minioClient, err
+	}
+
 	return &MinioClient{
-		Client:   client,
-		Location: location,
+		Client:      client,
+		AdminClient: adminClient,
+		Location:    location,
 	}, nil
 }
 
+// newTransportWithCA returns a http transport which trusts the certificates signed by caCert.
+func newTransportWithCA(caCert []byte) (*http.Transport, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("no valid certificate found in CA bundle")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+	return transport, nil
+}
+
 func (m MinioClient) IsBucketExists(bucket string) (bool, error) {
 	exists, err := m.Client.BucketExists(bucket)
 	if err != nil {
@@ -46,3 +96,104 @@ func (m MinioClient) CreateBucket(bucket string) error {
 	}
 	return nil
 }
+
+func (m MinioClient) AddUser(accessKey, secretKey string) error {
+	return m.AdminClient.AddUser(context.Background(), accessKey, secretKey)
+}
+
+func (m MinioClient) RemoveUser(accessKey string) error {
+	err := m.AdminClient.RemoveUser(context.Background(), accessKey)
+	if madmin.ToErrorResponse(err).Code == "XMinioAdminNoSuchUser" {
+		return nil
+	}
+	return err
+}
+
+func (m MinioClient) AddBucketPolicy(policyName string, buckets ...string) error {
+	policy, err := newBucketPolicy(buckets...)
+	if err != nil {
+		return err
+	}
+	return m.AdminClient.AddCannedPolicy(context.Background(), policyName, policy)
+}
+
+func (m MinioClient) SetUserPolicy(policyName, accessKey string) error {
+	return m.AdminClient.SetPolicy(context.Background(), policyName, accessKey, false)
+}
+
+func (m MinioClient) SetBucketEncryption(bucket string) error {
+	return m.Client.SetBucketEncryption(bucket, minv6.ServerSideEncryptionConfiguration{
+		Rules: []minv6.Rule{
+			{
+				Apply: minv6.ApplyServerSideEncryptionByDefault{
+					SSEAlgorithm: "AES256",
+				},
+			},
+		},
+	})
+}
+
+func (m MinioClient) ServerInfo(ctx context.Context) (madmin.InfoMessage, error) {
+	return m.AdminClient.ServerInfo(ctx)
+}
+
+func (m MinioClient) StorageInfo(ctx context.Context) (madmin.StorageInfo, error) {
+	return m.AdminClient.StorageInfo(ctx)
+}
+
+func (m MinioClient) BackgroundHealStatus(ctx context.Context) (madmin.BgHealState, error) {
+	return m.AdminClient.BackgroundHealStatus(ctx)
+}
+
+func (m MinioClient) DataUsageInfo(ctx context.Context) (madmin.DataUsageInfo, error) {
+	return m.AdminClient.DataUsageInfo(ctx)
+}
+
+// bucketActions are the actions of a harbor component on its bucket, listing the objects and the multipart uploads.
+var bucketActions = []string{
+	"s3:GetBucketLocation",
+	"s3:ListBucket",
+	"s3:ListBucketMultipartUploads",
+}
+
+// objectActions are the actions of a harbor component on the objects of its bucket.
+var objectActions = []string{
+	"s3:GetObject",
+	"s3:PutObject",
+	"s3:DeleteObject",
+	"s3:ListMultipartUploadParts",
+	"s3:AbortMultipartUpload",
+}
+
+// newBucketPolicy returns the policy which allows reading and writing the objects of the buckets.
+// The bucket configuration, e.g. its policy, lifecycle or deletion, is left to the minIO root user.
+func newBucketPolicy(buckets ...string) (*iampolicy.Policy, error) {
+	bucketResources := make([]string, 0, len(buckets))
+	objectResources := make([]string, 0, len(buckets))
+	for _, bucket := range buckets {
+		bucketResources = append(bucketResources, "arn:aws:s3:::"+bucket)
+		objectResources = append(objectResources, "arn:aws:s3:::"+bucket+"/*")
+	}
+
+	policy := map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Effect":   "Allow",
+				"Action":   bucketActions,
+				"Resource": bucketResources,
+			},
+			{
+				"Effect":   "Allow",
+				"Action":   objectActions,
+				"Resource": objectResources,
+			},
+		},
+	}
+	policyJson, err := json.Marshal(&policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return iampolicy.ParseConfig(bytes.NewReader(policyJson))
+}
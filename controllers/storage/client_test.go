@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"testing"
+
+	iampolicy "github.com/minio/minio/pkg/iam/policy"
+)
+
+func TestNewBucketPolicy(t *testing.T) {
+	policy, err := newBucketPolicy(DefaultBucket)
+	if err != nil {
+		t.Fatalf("newBucketPolicy() error = %v", err)
+	}
+
+	tests := []struct {
+		action iampolicy.Action
+		bucket string
+		object string
+		want   bool
+	}{
+		{action: iampolicy.GetObjectAction, bucket: DefaultBucket, object: "docker/registry/v2/blob", want: true},
+		{action: iampolicy.PutObjectAction, bucket: DefaultBucket, object: "docker/registry/v2/blob", want: true},
+		{action: iampolicy.DeleteObjectAction, bucket: DefaultBucket, object: "docker/registry/v2/blob", want: true},
+		{action: iampolicy.AbortMultipartUploadAction, bucket: DefaultBucket, object: "docker/registry/v2/blob", want: true},
+		{action: iampolicy.ListMultipartUploadPartsAction, bucket: DefaultBucket, object: "docker/registry/v2/blob", want: true},
+		{action: iampolicy.ListBucketAction, bucket: DefaultBucket, want: true},
+		{action: iampolicy.GetBucketLocationAction, bucket: DefaultBucket, want: true},
+		{action: iampolicy.ListBucketMultipartUploadsAction, bucket: DefaultBucket, want: true},
+		{action: iampolicy.DeleteBucketAction, bucket: DefaultBucket, want: false},
+		{action: iampolicy.PutBucketPolicyAction, bucket: DefaultBucket, want: false},
+		{action: iampolicy.PutBucketLifecycleAction, bucket: DefaultBucket, want: false},
+		{action: iampolicy.GetObjectAction, bucket: DefaultChartMuseumBucket, object: "index-cache.yaml", want: false},
+	}
+	for _, tt := range tests {
+		args := iampolicy.Args{Action: tt.action, BucketName: tt.bucket, ObjectName: tt.object}
+		if got := policy.IsAllowed(args); got != tt.want {
+			t.Errorf("IsAllowed(%s on %s/%s) = %v, want %v", tt.action, tt.bucket, tt.object, got, tt.want)
+		}
+	}
+}
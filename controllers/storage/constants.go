@@ -9,11 +9,28 @@ const (
 	GetMinIOSecretError     = "Get minIO secret error"
 	CreateMinIOError        = "Create minIO CR error"
 	ScaleMinIOError         = "Scale minIO error"
+	MinIOZonesNotReady      = "MinIO zones are not ready"
+	CreateMinIOUserError    = "Create minIO user error"
+	GetMinIOHealthError     = "Get minIO health error"
+	MinIOQuorumLost         = "MinIO write quorum lost"
 
-	CreateExternalSecretError = "Create external storage secret error"
-	GetExternalSecretError    = "Get external storage secret error"
-	UpdateExternalSecretError = "Update external storage secret error"
-	NotSupportType            = "The type of storage are not supported"
-	CreateDefaultBucketError  = "Create default bucket in minIO Error"
-	CreateDefaultBucketeError = "Create default buckete in minIO Error"
+	// StorageDegraded is the reason of the ready storage which has offline servers or drives.
+	StorageDegraded = "StorageDegraded"
+
+	CreateExternalSecretError    = "Create external storage secret error"
+	CreateExternalBucketError    = "Create external storage bucket error"
+	GetExternalSecretError       = "Get external storage secret error"
+	UpdateExternalSecretError    = "Update external storage secret error"
+	GetExternalCredentialError   = "Get external storage credential error"
+	StorageUnreachableError      = "External storage unreachable"
+	StorageTLSError              = "External storage TLS error"
+	StorageAuthFailedError       = "External storage authentication failed"
+	StorageBucketMissingError    = "External storage bucket missing"
+	StoragePermissionDeniedError = "External storage permission denied"
+	StorageProbeError            = "External storage check error"
+	MigrateStorageError          = "Migrate storage error"
+	MigratingStorage             = "Migrating storage"
+	NotSupportType               = "The type of storage are not supported"
+	CreateDefaultBucketError     = "Create default bucket in minIO Error"
+	CreateDefaultBucketeError    = "Create default buckete in minIO Error"
 )
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"fmt"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// getCredential returns the plain value if it is set, otherwise the value of the referenced secret key.
+func (m *MinIOReconciler) getCredential(plain string, ref *goharborv1.SecretKeyRef) (string, error) {
+	if ref == nil {
+		return plain, nil
+	}
+
+	namespacedName, err := m.getSecretRefNamespacedName(ref)
+	if err != nil {
+		return "", err
+	}
+
+	var secret corev1.Secret
+	err = m.KubeClient.Get(namespacedName, &secret)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found in secret %s", ref.Key, namespacedName)
+	}
+
+	return string(value), nil
+}
+
+// getSecretRefNamespacedName returns the namespaced name of the referenced secret,
+// a namespace other than the harbor cluster one must be allowed by the operator.
+func (m *MinIOReconciler) getSecretRefNamespacedName(ref *goharborv1.SecretKeyRef) (types.NamespacedName, error) {
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = m.HarborCluster.Namespace
+	}
+
+	if namespace != m.HarborCluster.Namespace && !IsSecretNamespaceAllowed(namespace, m.AllowedSecretNamespaces) {
+		return types.NamespacedName{}, fmt.Errorf("secret namespace %s is not allowed", namespace)
+	}
+
+	return types.NamespacedName{
+		Namespace: namespace,
+		Name:      ref.Name,
+	}, nil
+}
+
+// IsSecretNamespaceAllowed check whether secrets of the namespace can be referenced across namespaces.
+func IsSecretNamespaceAllowed(namespace string, allowed []string) bool {
+	for _, ns := range allowed {
+		if ns == namespace || ns == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSecretRefs returns all secret references of the storage spec.
+func GetSecretRefs(storage *goharborv1.Storage) []*goharborv1.SecretKeyRef {
+	var refs []*goharborv1.SecretKeyRef
+	if storage == nil {
+		return refs
+	}
+
+	add := func(ref *goharborv1.SecretKeyRef) {
+		if ref != nil {
+			refs = append(refs, ref)
+		}
+	}
+
+	if storage.Azure != nil {
+		add(storage.Azure.AccountKeyRef)
+	}
+	if storage.Gcs != nil {
+		add(storage.Gcs.EncodedKeyRef)
+	}
+	if storage.S3 != nil {
+		add(storage.S3.AccessKeyRef)
+		add(storage.S3.SecretKeyRef)
+	}
+	if storage.Swift != nil {
+		add(storage.Swift.PasswordRef)
+		add(storage.Swift.SecretKeyRef)
+	}
+	if storage.Oss != nil {
+		add(storage.Oss.AccessKeySecretRef)
+	}
+
+	return refs
+}
+
+// IsSecretReferenced check whether the secret is referenced by storage of the harbor cluster.
+func IsSecretReferenced(harborCluster *goharborv1.HarborCluster, secret types.NamespacedName) bool {
+	for _, ref := range GetSecretRefs(harborCluster.Spec.Storage) {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = harborCluster.Namespace
+		}
+		if namespace == secret.Namespace && ref.Name == secret.Name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"fmt"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	"github.com/goharbor/harbor-cluster-operator/controllers/harbor"
+	"github.com/goharbor/harbor-cluster-operator/lcm"
+	minio "github.com/minio/minio-operator/pkg/apis/operator.min.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// DefaultDistributedMinIOSuffix is appended to the name of the minIO instance which replaces
+	// a standalone minIO upgraded to distributed mode.
+	DefaultDistributedMinIOSuffix = "distributed"
+)
+
+func (m *MinIOReconciler) getDefaultInstanceName() string {
+	return m.HarborCluster.Name + "-" + DefaultMinIO
+}
+
+func (m *MinIOReconciler) getDistributedInstanceName() string {
+	return m.getDefaultInstanceName() + "-" + DefaultDistributedMinIOSuffix
+}
+
+// getServiceSelector selects the pods of the minIO instance only,
+// since the pods of the minIO instances of a harbor cluster have the same labels.
+func (m *MinIOReconciler) getServiceSelector() map[string]string {
+	selector := m.getLabels()
+	selector[minio.InstanceLabel] = m.getServiceName()
+	return selector
+}
+
+// ensureServiceSelector updates the service created without the instance label in its selector,
+// which would select the pods of another minIO instance too.
+func (m *MinIOReconciler) ensureServiceSelector() error {
+	var service corev1.Service
+	err := m.KubeClient.Get(m.getMinIONamespacedName(), &service)
+	if err != nil {
+		return err
+	}
+
+	selector := m.getServiceSelector()
+	if service.Spec.Selector[minio.InstanceLabel] == selector[minio.InstanceLabel] {
+		return nil
+	}
+
+	m.Log.Info("Updating minIO service selector", "namespace", service.Namespace, "name", service.Name)
+	service.Spec.Selector = selector
+	return m.KubeClient.Update(&service)
+}
+
+// forInstance returns a copy of the reconciler which manages the given minIO instance.
+func (m *MinIOReconciler) forInstance(name string) *MinIOReconciler {
+	reconciler := *m
+	reconciler.instanceName = name
+	reconciler.CurrentMinIOCR = nil
+	reconciler.DesiredMinIOCR = nil
+	reconciler.MinioClient = nil
+	return &reconciler
+}
+
+// resolveMinIOInstance selects the minIO instance serving harbor. A standalone minIO upgraded to distributed
+// mode is replaced by a new minIO instance, which serves harbor once the objects are copied to it.
+func (m *MinIOReconciler) resolveMinIOInstance() error {
+	migration := m.getDistributedMigration()
+	if migration != nil && migration.Phase != goharborv1.StorageMigrationSwitching {
+		m.instanceName = migration.SourceInstance
+		return nil
+	}
+
+	var minioCR minio.MinIOInstance
+	err := m.KubeClient.Get(types.NamespacedName{Namespace: m.HarborCluster.Namespace, Name: m.getDistributedInstanceName()}, &minioCR)
+	if k8serror.IsNotFound(err) || meta.IsNoMatchError(err) {
+		m.instanceName = m.getDefaultInstanceName()
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	m.instanceName = m.getDistributedInstanceName()
+	return nil
+}
+
+// getDistributedMigration returns the migration from a standalone to a distributed minIO, if it is not completed.
+func (m *MinIOReconciler) getDistributedMigration() *goharborv1.StorageMigrationStatus {
+	migration := m.getStorageMigration()
+	if migration == nil || migration.SourceInstance == "" || migration.Phase == goharborv1.StorageMigrationCompleted {
+		return nil
+	}
+	return migration
+}
+
+// isDistributedMigrationNeeded check whether the standalone minIO instance is upgraded to distributed mode.
+func (m *MinIOReconciler) isDistributedMigrationNeeded() bool {
+	if m.getDistributedMigration() != nil {
+		return true
+	}
+	return isStandaloneInstance(m.CurrentMinIOCR) && !isStandaloneInstance(m.DesiredMinIOCR)
+}
+
+func isStandaloneInstance(minioInstance *minio.MinIOInstance) bool {
+	zones := minioInstance.Spec.Zones
+	return len(zones) == 1 && zones[0].Servers == 1
+}
+
+// ReconcileDistributedMigration migrates a standalone minIO to distributed mode. The erasure sets of a minIO
+// can never be changed, so a new distributed minIO instance is provisioned and the objects are copied to it.
+// It does:
+// - provision the distributed minIO instance with the buckets and users of harbor
+// - put harbor into read-only mode
+// - copy the objects of the standalone minIO buckets to the same buckets, a batch in every reconciliation
+// - verify every object is copied with the same size, the mismatched buckets are copied again
+// - switch harbor to the distributed minIO
+// - leave read-only mode and release the standalone minIO instance once harbor is ready with the distributed minIO
+// The progress is kept in the status of the harbor cluster, so the migration is resumed after interruptions.
+func (m *MinIOReconciler) ReconcileDistributedMigration() (*lcm.CRStatus, error) {
+	source := m.forInstance(m.getDefaultInstanceName())
+	target := m.forInstance(m.getDistributedInstanceName())
+
+	migration := m.getDistributedMigration()
+	if migration == nil {
+		// the service of the standalone minIO would select the pods of the distributed minIO too.
+		err := source.ensureServiceSelector()
+		if err != nil {
+			return minioNotReadyStatus(CreateMinIOServiceError, err.Error()), err
+		}
+
+		now := metav1.Now()
+		migration = &goharborv1.StorageMigrationStatus{
+			SourceKind:     inClusterStorage,
+			TargetKind:     inClusterStorage,
+			SourceInstance: source.getServiceName(),
+			TargetInstance: target.getServiceName(),
+			Phase:          goharborv1.StorageMigrationProvisioning,
+			StartTime:      &now,
+		}
+		m.HarborCluster.Status.StorageMigration = migration
+	}
+
+	// the errors are kept in status and retried, instead of being returned,
+	// so that the progress of the migration is saved in the status of the harbor cluster.
+	phase := migration.Phase
+	err := m.migrateToDistributed(migration, source, target)
+	if err != nil {
+		m.Log.Error(err, "Storage migration failed, it will be retried", "phase", migration.Phase)
+		migration.Message = err.Error()
+		return minioNotReadyStatus(MigrateStorageError, err.Error()), nil
+	}
+	migration.Message = ""
+
+	m.recordMigrationPhase(migration, phase)
+
+	switch migration.Phase {
+	case goharborv1.StorageMigrationSwitching, goharborv1.StorageMigrationCompleted:
+		targetCR, err := target.getMinIOInstance()
+		if err != nil {
+			return minioNotReadyStatus(GetMinIOError, err.Error()), err
+		}
+		return target.ProvisionInClusterSecretAsS3(targetCR)
+	default:
+		return minioMigratingStatus(migration), nil
+	}
+}
+
+func (m *MinIOReconciler) migrateToDistributed(migration *goharborv1.StorageMigrationStatus, source, target *MinIOReconciler) error {
+	switch migration.Phase {
+	case goharborv1.StorageMigrationProvisioning:
+		isReady, err := target.provisionInstance()
+		if err != nil || !isReady {
+			return err
+		}
+		migration.Phase = goharborv1.StorageMigrationReadOnly
+	case goharborv1.StorageMigrationSwitching:
+		applied, err := harbor.IsStorageSecretApplied(m.KubeClient, m.HarborCluster, target.getServiceName())
+		if err != nil || !applied {
+			return err
+		}
+
+		err = m.restoreReadOnly(migration)
+		if err != nil {
+			return err
+		}
+
+		err = source.release()
+		if err != nil {
+			return err
+		}
+
+		now := metav1.Now()
+		migration.CompletionTime = &now
+		migration.Phase = goharborv1.StorageMigrationCompleted
+	default:
+		getTargets := func() (*s3Target, *s3Target, error) {
+			sourceTarget, err := source.getInstanceTarget()
+			if err != nil {
+				return nil, nil, err
+			}
+			targetTarget, err := target.getInstanceTarget()
+			if err != nil {
+				return nil, nil, err
+			}
+			return sourceTarget, targetTarget, nil
+		}
+		return m.migrateObjects(migration, getTargets, getInstanceMigrationBuckets)
+	}
+
+	return nil
+}
+
+// provisionInstance provisions the minIO instance, and returns whether it is ready with the buckets and users of harbor.
+func (m *MinIOReconciler) provisionInstance() (bool, error) {
+	m.DesiredMinIOCR = m.generateMinIOCR()
+
+	var minioCR minio.MinIOInstance
+	err := m.KubeClient.Get(m.getMinIONamespacedName(), &minioCR)
+	if k8serror.IsNotFound(err) {
+		_, err := m.Provision()
+		return false, err
+	} else if err != nil {
+		return false, err
+	}
+
+	m.CurrentMinIOCR = &minioCR
+
+	isReady, _, _, err := m.checkMinIOReady()
+	if err != nil || !isReady {
+		return false, err
+	}
+
+	err = m.minioInit()
+	if err != nil {
+		return false, err
+	}
+	err = m.ensureMinIOUsers(&minioCR)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (m *MinIOReconciler) getMinIOInstance() (*minio.MinIOInstance, error) {
+	var minioCR minio.MinIOInstance
+	err := m.KubeClient.Get(m.getMinIONamespacedName(), &minioCR)
+	if err != nil {
+		return nil, err
+	}
+	return &minioCR, nil
+}
+
+// getInstanceMigrationBuckets returns the buckets to migrate with their size,
+// every bucket is copied to the bucket of the same name.
+func getInstanceMigrationBuckets(source, target *s3Target) ([]goharborv1.BucketMigrationStatus, error) {
+	client, err := source.newClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var buckets []goharborv1.BucketMigrationStatus
+	for _, bucket := range []string{DefaultBucket, DefaultChartMuseumBucket} {
+		exists, err := client.BucketExists(bucket)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			buckets = append(buckets, goharborv1.BucketMigrationStatus{Source: bucket, Target: bucket})
+		}
+	}
+
+	return countMigrationObjects(client, buckets)
+}
+
+// release deletes the migrated minIO instance, the other resources are owned by the minIO instance. The persistent volume claims are kept.
+func (m *MinIOReconciler) release() error {
+	minioCR, err := m.getMinIOInstance()
+	if err == nil {
+		m.Log.Info("Releasing the migrated minIO", "namespace", minioCR.Namespace, "name", minioCR.Name)
+		err = m.KubeClient.Delete(minioCR)
+	}
+	if err != nil && !k8serror.IsNotFound(err) {
+		return fmt.Errorf("delete minIO %s: %w", m.getServiceName(), err)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	minio "github.com/minio/minio-operator/pkg/apis/operator.min.io/v1"
+)
+
+func newMinIOInstance(servers ...int32) *minio.MinIOInstance {
+	instance := &minio.MinIOInstance{}
+	for _, count := range servers {
+		instance.Spec.Zones = append(instance.Spec.Zones, minio.Zone{Servers: count})
+	}
+	return instance
+}
+
+func TestIsDistributedMigrationNeeded(t *testing.T) {
+	tests := []struct {
+		name      string
+		current   *minio.MinIOInstance
+		desired   *minio.MinIOInstance
+		migration *goharborv1.StorageMigrationStatus
+		want      bool
+	}{
+		{
+			name:    "standalone minIO",
+			current: newMinIOInstance(1),
+			desired: newMinIOInstance(1),
+			want:    false,
+		},
+		{
+			name:    "standalone upgraded to distributed mode",
+			current: newMinIOInstance(1),
+			desired: newMinIOInstance(4),
+			want:    true,
+		},
+		{
+			name:    "distributed minIO expanded with a pool",
+			current: newMinIOInstance(4),
+			desired: newMinIOInstance(4, 4),
+			want:    false,
+		},
+		{
+			name:    "migration in progress",
+			current: newMinIOInstance(4),
+			desired: newMinIOInstance(4),
+			migration: &goharborv1.StorageMigrationStatus{
+				SourceKind:     inClusterStorage,
+				TargetKind:     inClusterStorage,
+				SourceInstance: "harbor-minio",
+				Phase:          goharborv1.StorageMigrationCopying,
+			},
+			want: true,
+		},
+		{
+			name:    "migration completed",
+			current: newMinIOInstance(4),
+			desired: newMinIOInstance(4),
+			migration: &goharborv1.StorageMigrationStatus{
+				SourceKind:     inClusterStorage,
+				TargetKind:     inClusterStorage,
+				SourceInstance: "harbor-minio",
+				Phase:          goharborv1.StorageMigrationCompleted,
+			},
+			want: false,
+		},
+		{
+			name:    "migration from an external storage",
+			current: newMinIOInstance(4),
+			desired: newMinIOInstance(4),
+			migration: &goharborv1.StorageMigrationStatus{
+				SourceKind: s3Storage,
+				TargetKind: inClusterStorage,
+				Phase:      goharborv1.StorageMigrationCopying,
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &MinIOReconciler{
+				HarborCluster: &goharborv1.HarborCluster{
+					Spec:   goharborv1.HarborClusterSpec{Storage: &goharborv1.Storage{Kind: inClusterStorage}},
+					Status: goharborv1.HarborClusterStatus{StorageMigration: tt.migration},
+				},
+				CurrentMinIOCR: tt.current,
+				DesiredMinIOCR: tt.desired,
+			}
+			if got := m.isDistributedMigrationNeeded(); got != tt.want {
+				t.Errorf("isDistributedMigrationNeeded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetServiceSelector(t *testing.T) {
+	m := &MinIOReconciler{HarborCluster: &goharborv1.HarborCluster{}}
+	m.HarborCluster.Name = "harbor"
+
+	standalone := m.forInstance(m.getDefaultInstanceName()).getServiceSelector()
+	distributed := m.forInstance(m.getDistributedInstanceName()).getServiceSelector()
+
+	if got, want := standalone[minio.InstanceLabel], "harbor-minio"; got != want {
+		t.Errorf("getServiceSelector() instance = %v, want %v", got, want)
+	}
+	if got, want := distributed[minio.InstanceLabel], "harbor-minio-distributed"; got != want {
+		t.Errorf("getServiceSelector() instance = %v, want %v", got, want)
+	}
+}
+
+func TestGetInstanceMigrationBuckets(t *testing.T) {
+	tests := []struct {
+		name    string
+		buckets []string
+		want    []goharborv1.BucketMigrationStatus
+	}{
+		{
+			name:    "registry bucket only",
+			buckets: []string{DefaultBucket},
+			want: []goharborv1.BucketMigrationStatus{
+				{Source: DefaultBucket, Target: DefaultBucket, TotalObjects: 2, TotalBytes: 7},
+			},
+		},
+		{
+			name:    "registry and chartmuseum buckets",
+			buckets: []string{DefaultBucket, DefaultChartMuseumBucket},
+			want: []goharborv1.BucketMigrationStatus{
+				{Source: DefaultBucket, Target: DefaultBucket, TotalObjects: 2, TotalBytes: 7},
+				{Source: DefaultChartMuseumBucket, Target: DefaultChartMuseumBucket, TotalObjects: 1, TotalBytes: 6},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newFakeS3Server(tt.buckets...)
+			defer source.Close()
+			source.putObject(DefaultBucket, "blobs/a", "aaa", time.Now())
+			source.putObject(DefaultBucket, "blobs/b", "bbbb", time.Now())
+			if len(tt.buckets) > 1 {
+				source.putObject(DefaultChartMuseumBucket, "index.yaml", "charts", time.Now())
+			}
+			target := newFakeS3Server(tt.buckets...)
+			defer target.Close()
+
+			got, err := getInstanceMigrationBuckets(source.getTarget(""), target.getTarget(""))
+			if err != nil {
+				t.Fatalf("getInstanceMigrationBuckets() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("getInstanceMigrationBuckets() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("getInstanceMigrationBuckets()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMigrateInstanceObjects(t *testing.T) {
+	source := newFakeS3Server(DefaultBucket, DefaultChartMuseumBucket)
+	defer source.Close()
+	source.putObject(DefaultBucket, "blobs/a", "aaa", time.Now())
+	source.putObject(DefaultBucket, "blobs/b", "bbbb", time.Now())
+	source.putObject(DefaultBucket, "blobs/c", "cc", time.Now())
+	source.putObject(DefaultChartMuseumBucket, "index.yaml", "charts", time.Now())
+	source.maxKeys = 2
+
+	target := newFakeS3Server(DefaultBucket, DefaultChartMuseumBucket)
+	defer target.Close()
+	target.maxKeys = 2
+
+	getTargets := func() (*s3Target, *s3Target, error) {
+		return source.getTarget(""), target.getTarget(""), nil
+	}
+	buckets, err := getInstanceMigrationBuckets(source.getTarget(""), target.getTarget(""))
+	if err != nil {
+		t.Fatalf("getInstanceMigrationBuckets() error = %v", err)
+	}
+
+	m := &MinIOReconciler{Ctx: context.Background()}
+	migration := &goharborv1.StorageMigrationStatus{
+		SourceKind: inClusterStorage,
+		TargetKind: inClusterStorage,
+		Phase:      goharborv1.StorageMigrationCopying,
+		Buckets:    buckets,
+	}
+
+	err = m.migrateObjects(migration, getTargets, getInstanceMigrationBuckets)
+	if err != nil {
+		t.Fatalf("migrateObjects() error = %v", err)
+	}
+	if migration.Phase != goharborv1.StorageMigrationVerifying {
+		t.Fatalf("migrateObjects() phase = %v, want %v", migration.Phase, goharborv1.StorageMigrationVerifying)
+	}
+	for _, bucket := range migration.Buckets {
+		if !bucket.Done || bucket.CopiedObjects != bucket.TotalObjects || bucket.CopiedBytes != bucket.TotalBytes {
+			t.Errorf("migrateObjects() bucket = %v, want every object copied", bucket)
+		}
+	}
+	for bucket, objects := range source.buckets {
+		for key, content := range objects {
+			if got, ok := target.getObject(bucket, key); !ok || got != string(content) {
+				t.Errorf("object %s/%s = %q, want %q", bucket, key, got, content)
+			}
+		}
+	}
+
+	// an object truncated in the target is detected, and its bucket is copied again.
+	target.putObject(DefaultBucket, "blobs/b", "b", time.Now())
+	err = m.migrateObjects(migration, getTargets, getInstanceMigrationBuckets)
+	if err == nil {
+		t.Fatalf("migrateObjects() error = nil, want a size mismatch")
+	}
+	if migration.Phase != goharborv1.StorageMigrationCopying {
+		t.Fatalf("migrateObjects() phase = %v, want %v", migration.Phase, goharborv1.StorageMigrationCopying)
+	}
+	if bucket := migration.Buckets[0]; bucket.Done || bucket.LastKey != "" || bucket.CopiedObjects != 0 {
+		t.Errorf("migrateObjects() bucket = %v, want the copy restarted", bucket)
+	}
+
+	for _, phase := range []goharborv1.StorageMigrationPhase{goharborv1.StorageMigrationVerifying, goharborv1.StorageMigrationSwitching} {
+		err = m.migrateObjects(migration, getTargets, getInstanceMigrationBuckets)
+		if err != nil {
+			t.Fatalf("migrateObjects() error = %v", err)
+		}
+		if migration.Phase != phase {
+			t.Fatalf("migrateObjects() phase = %v, want %v", migration.Phase, phase)
+		}
+	}
+	if got, _ := target.getObject(DefaultBucket, "blobs/b"); got != "bbbb" {
+		t.Errorf("object %s/blobs/b = %q, want %q", DefaultBucket, got, "bbbb")
+	}
+}
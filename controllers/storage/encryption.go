@@ -0,0 +1,43 @@
+package storage
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+func (m *MinIOReconciler) isEncryptionEnabled() bool {
+	return m.HarborCluster.Spec.Storage.InCluster.Spec.Encryption != nil
+}
+
+// getMinIOEnv returns the environment variables of minIO. If the encryption is enabled, every object is encrypted
+// with SSE-S3, the object keys are protected by the master key.
+// The old root credentials are only set in the creds secret during a rotation, minIO re-encrypts its config with
+// the new ones when it starts.
+func (m *MinIOReconciler) getMinIOEnv() []corev1.EnvVar {
+	env := []corev1.EnvVar{
+		{
+			Name:  "MINIO_BROWSER",
+			Value: "on",
+		},
+		m.getOptionalCredsEnv("MINIO_ACCESS_KEY_OLD", oldAccessKey),
+		m.getOptionalCredsEnv("MINIO_SECRET_KEY_OLD", oldSecretKey),
+	}
+	if !m.isEncryptionEnabled() {
+		return env
+	}
+
+	env = append(env, corev1.EnvVar{
+		Name:  "MINIO_KMS_AUTO_ENCRYPTION",
+		Value: "on",
+	})
+
+	masterKeySecret := m.HarborCluster.Spec.Storage.InCluster.Spec.Encryption.MasterKeySecret
+	if masterKeySecret != nil {
+		env = append(env, corev1.EnvVar{
+			Name: "MINIO_KMS_MASTER_KEY",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: masterKeySecret.DeepCopy(),
+			},
+		})
+	}
+	return env
+}
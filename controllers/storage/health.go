@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/minio/minio/pkg/madmin"
+)
+
+const (
+	minioHealthTimeout = 10 * time.Second
+	minioStateOK       = "ok"
+)
+
+// minioHealth is the health of the minIO servers and drives reported by the admin API.
+type minioHealth struct {
+	Servers        int
+	OfflineServers int
+	Drives         int
+	OfflineDrives  int
+	// QuorumLost is true if an erasure set has less online drives than its write quorum,
+	// the objects of the set can not be written anymore.
+	QuorumLost bool
+	// LastHealActivity is the last time the background healing repaired an object.
+	LastHealActivity time.Time
+}
+
+// isDegraded returns whether a server or a drive is offline while minIO can still serve reads and writes.
+func (h *minioHealth) isDegraded() bool {
+	return !h.QuorumLost && (h.OfflineServers > 0 || h.OfflineDrives > 0)
+}
+
+func (h *minioHealth) String() string {
+	messages := []string{
+		fmt.Sprintf("%d/%d servers online", h.Servers-h.OfflineServers, h.Servers),
+		fmt.Sprintf("%d/%d drives online", h.Drives-h.OfflineDrives, h.Drives),
+	}
+	if h.QuorumLost {
+		messages = append(messages, "write quorum lost")
+	}
+	if !h.LastHealActivity.IsZero() {
+		messages = append(messages, "last heal activity at "+h.LastHealActivity.UTC().Format(time.RFC3339))
+	}
+	return strings.Join(messages, ", ")
+}
+
+// checkMinIOHealth returns the health of minIO from the server info, the drive states of the erasure sets
+// and the background healing status.
+func (m *MinIOReconciler) checkMinIOHealth() (*minioHealth, error) {
+	ctx, cancel := context.WithTimeout(m.Ctx, minioHealthTimeout)
+	defer cancel()
+
+	serverInfo, err := m.MinioClient.ServerInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get minIO server info: %w", err)
+	}
+
+	health := &minioHealth{}
+	for _, server := range serverInfo.Servers {
+		health.Servers++
+		if server.State != minioStateOK {
+			health.OfflineServers++
+		}
+	}
+
+	storageInfo, err := m.MinioClient.StorageInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get minIO storage info: %w", err)
+	}
+
+	if storageInfo.Backend.Type != madmin.Erasure {
+		// a standalone minIO has a single drive, which is online if the server responds.
+		health.Drives = len(storageInfo.MountPaths)
+		return health, nil
+	}
+
+	for _, set := range storageInfo.Backend.Sets {
+		online := 0
+		for _, drive := range set {
+			if drive.State == minioStateOK {
+				online++
+			}
+		}
+		health.Drives += len(set)
+		health.OfflineDrives += len(set) - online
+
+		if online < getWriteQuorum(len(set), storageInfo.Backend.StandardSCParity) {
+			health.QuorumLost = true
+		}
+	}
+
+	healState, err := m.MinioClient.BackgroundHealStatus(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get minIO heal status: %w", err)
+	}
+	health.LastHealActivity = healState.LastHealActivity
+
+	return health, nil
+}
+
+// getWriteQuorum returns the number of drives of an erasure set which must be online to write objects,
+// the parity defaults to half of the drives.
+func getWriteQuorum(drives, parity int) int {
+	if parity == 0 {
+		parity = drives / 2
+	}
+	data := drives - parity
+	if data == parity {
+		return data + 1
+	}
+	return data
+}
@@ -0,0 +1,514 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	"github.com/goharbor/harbor-cluster-operator/controllers/harbor"
+	"github.com/goharbor/harbor-cluster-operator/lcm"
+	minv6 "github.com/minio/minio-go/v6"
+	"github.com/minio/minio-go/v6/pkg/signer"
+	minio "github.com/minio/minio-operator/pkg/apis/operator.min.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	StorageMigrationEvent = "StorageMigration"
+
+	// DefaultMigrationBatchDuration is how long objects are copied in a reconciliation,
+	// the copy is resumed in the next one.
+	DefaultMigrationBatchDuration = 30 * time.Second
+
+	listObjectsMaxKeys = 1000
+	unsignedPayload    = "UNSIGNED-PAYLOAD"
+)
+
+// getMigrationSource returns the in-cluster minIO instance which is migrated to the external storage.
+// The storage is migrated while the minIO instance still exists after the storage kind is switched to s3 or oss.
+func (m *MinIOReconciler) getMigrationSource() (*minio.MinIOInstance, error) {
+	kind := m.HarborCluster.Spec.Storage.Kind
+	if kind != s3Storage && kind != ossStorage {
+		return nil, nil
+	}
+
+	migration := m.getStorageMigration()
+	if migration != nil && migration.Phase == goharborv1.StorageMigrationCompleted {
+		return nil, nil
+	}
+
+	var minioCR minio.MinIOInstance
+	err := m.KubeClient.Get(m.getMinIONamespacedName(), &minioCR)
+	if k8serror.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &minioCR, nil
+}
+
+// ReconcileMigration migrates the objects of the in-cluster minIO to the external storage.
+// It does:
+// - check the external storage, then put harbor into read-only mode
+// - copy the objects of the minIO buckets to the external bucket, a batch in every reconciliation
+// - verify every object is copied with the same size, the mismatched buckets are copied again
+// - switch harbor to the external storage
+// - leave read-only mode and release the minIO instance once harbor is ready with the external storage
+// The progress is kept in the status of the harbor cluster, so the migration is resumed after interruptions.
+func (m *MinIOReconciler) ReconcileMigration(source *minio.MinIOInstance) (*lcm.CRStatus, error) {
+	m.CurrentMinIOCR = source
+
+	exSecret, err := m.generateExternalSecret()
+	if err != nil {
+		return minioNotReadyStatus(GetExternalCredentialError, err.Error()), err
+	}
+	err = m.applySecret(exSecret)
+	if err != nil {
+		return minioNotReadyStatus(CreateExternalSecretError, err.Error()), err
+	}
+	err = m.applyExternalChartMuseumSecret()
+	if err != nil {
+		return minioNotReadyStatus(CreateExternalSecretError, err.Error()), err
+	}
+
+	migration := m.getStorageMigration()
+	if migration == nil {
+		// harbor stays writable until the external storage is usable.
+		err := m.ensureExternalBucket()
+		if err != nil {
+			return minioNotReadyStatus(CreateExternalBucketError, err.Error()), nil
+		}
+
+		err = m.probeExternalStorage()
+		if err != nil {
+			return minioNotReadyStatus(getProbeReason(err), err.Error()), nil
+		}
+
+		now := metav1.Now()
+		migration = &goharborv1.StorageMigrationStatus{
+			SourceKind: inClusterStorage,
+			TargetKind: m.HarborCluster.Spec.Storage.Kind,
+			Phase:      goharborv1.StorageMigrationReadOnly,
+			StartTime:  &now,
+		}
+		m.HarborCluster.Status.StorageMigration = migration
+	}
+
+	// the errors are kept in status and retried, instead of being returned,
+	// so that the progress of the migration is saved in the status of the harbor cluster.
+	phase := migration.Phase
+	err = m.migrate(migration, source)
+	if err != nil {
+		m.Log.Error(err, "Storage migration failed, it will be retried", "phase", migration.Phase)
+		migration.Message = err.Error()
+		return minioNotReadyStatus(MigrateStorageError, err.Error()), nil
+	}
+	migration.Message = ""
+
+	m.recordMigrationPhase(migration, phase)
+
+	switch migration.Phase {
+	case goharborv1.StorageMigrationSwitching, goharborv1.StorageMigrationCompleted:
+		return m.externalReadyStatus(), nil
+	default:
+		return minioMigratingStatus(migration), nil
+	}
+}
+
+// getStorageMigration returns the storage migration to the current storage kind,
+// a migration to another storage kind is finished and ignored.
+func (m *MinIOReconciler) getStorageMigration() *goharborv1.StorageMigrationStatus {
+	migration := m.HarborCluster.Status.StorageMigration
+	if migration == nil || migration.TargetKind != m.HarborCluster.Spec.Storage.Kind {
+		return nil
+	}
+	return migration
+}
+
+func (m *MinIOReconciler) recordMigrationPhase(migration *goharborv1.StorageMigrationStatus, phase goharborv1.StorageMigrationPhase) {
+	if migration.Phase == phase {
+		return
+	}
+
+	source, target := getMigrationEnds(migration)
+	m.Log.Info("Storage migration phase changed", "from", phase, "to", migration.Phase)
+	m.Recorder.Event(m.HarborCluster, corev1.EventTypeNormal, StorageMigrationEvent,
+		fmt.Sprintf("The storage migration from %s to %s is in phase %s", source, target, migration.Phase))
+}
+
+// getMigrationEnds returns what the storage is migrated from and to,
+// which are the minIO instances if the in-cluster minIO is migrated to distributed mode.
+func getMigrationEnds(migration *goharborv1.StorageMigrationStatus) (string, string) {
+	if migration.SourceInstance != "" {
+		return migration.SourceInstance, migration.TargetInstance
+	}
+	return migration.SourceKind, migration.TargetKind
+}
+
+func (m *MinIOReconciler) migrate(migration *goharborv1.StorageMigrationStatus, source *minio.MinIOInstance) error {
+	if migration.Phase != goharborv1.StorageMigrationSwitching {
+		getTargets := func() (*s3Target, *s3Target, error) {
+			return m.getMigrationTargets()
+		}
+		return m.migrateObjects(migration, getTargets, getMigrationBuckets)
+	}
+
+	applied, err := harbor.IsStorageSecretApplied(m.KubeClient, m.HarborCluster, m.getExternalSecretName())
+	if err != nil || !applied {
+		return err
+	}
+
+	err = m.restoreReadOnly(migration)
+	if err != nil {
+		return err
+	}
+
+	m.Log.Info("Releasing the migrated minIO", "namespace", source.Namespace, "name", source.Name)
+	err = m.KubeClient.Delete(source)
+	if err != nil && !k8serror.IsNotFound(err) {
+		return err
+	}
+
+	now := metav1.Now()
+	migration.CompletionTime = &now
+	migration.Phase = goharborv1.StorageMigrationCompleted
+	return nil
+}
+
+// enterReadOnly puts harbor into read-only mode, the previous mode is recorded at first to be restored by restoreReadOnly.
+func (m *MinIOReconciler) enterReadOnly(migration *goharborv1.StorageMigrationStatus) error {
+	if migration.PreviousReadOnly == nil {
+		readOnly, err := harbor.GetReadOnly(m.Ctx, m.KubeClient, m.HarborCluster)
+		if err != nil {
+			return err
+		}
+		migration.PreviousReadOnly = &readOnly
+	}
+	return harbor.SetReadOnly(m.Ctx, m.KubeClient, m.HarborCluster, true)
+}
+
+// restoreReadOnly restores the mode of harbor before the migration, so that harbor which was put into read-only mode
+// by the administrator stays read-only.
+func (m *MinIOReconciler) restoreReadOnly(migration *goharborv1.StorageMigrationStatus) error {
+	return harbor.SetReadOnly(m.Ctx, m.KubeClient, m.HarborCluster, getPreviousReadOnly(migration))
+}
+
+// getPreviousReadOnly returns the mode of harbor before the migration, harbor is writable if the mode is not recorded.
+func getPreviousReadOnly(migration *goharborv1.StorageMigrationStatus) bool {
+	return migration.PreviousReadOnly != nil && *migration.PreviousReadOnly
+}
+
+// migrateObjects puts harbor into read-only mode, then copies the objects of the buckets from the source
+// to the target storage and verifies them. It returns in phase Switching once all objects are verified.
+func (m *MinIOReconciler) migrateObjects(migration *goharborv1.StorageMigrationStatus,
+	getTargets func() (*s3Target, *s3Target, error),
+	getBuckets func(source, target *s3Target) ([]goharborv1.BucketMigrationStatus, error)) error {
+	switch migration.Phase {
+	case goharborv1.StorageMigrationReadOnly:
+		err := m.enterReadOnly(migration)
+		if err != nil {
+			return err
+		}
+
+		sourceTarget, target, err := getTargets()
+		if err != nil {
+			return err
+		}
+		migration.Buckets, err = getBuckets(sourceTarget, target)
+		if err != nil {
+			return err
+		}
+		migration.Phase = goharborv1.StorageMigrationCopying
+	case goharborv1.StorageMigrationCopying:
+		sourceTarget, target, err := getTargets()
+		if err != nil {
+			return err
+		}
+		done, err := m.copyObjects(sourceTarget, target, migration.Buckets, time.Now().Add(DefaultMigrationBatchDuration))
+		if err != nil {
+			return err
+		}
+		if done {
+			migration.Phase = goharborv1.StorageMigrationVerifying
+		}
+	case goharborv1.StorageMigrationVerifying:
+		sourceTarget, target, err := getTargets()
+		if err != nil {
+			return err
+		}
+		for i := range migration.Buckets {
+			err := verifyObjects(sourceTarget, target, &migration.Buckets[i])
+			if err != nil {
+				// copy the bucket again, the copied objects are overwritten.
+				migration.Buckets[i].LastKey = ""
+				migration.Buckets[i].CopiedObjects = 0
+				migration.Buckets[i].CopiedBytes = 0
+				migration.Buckets[i].Done = false
+				migration.Phase = goharborv1.StorageMigrationCopying
+				return err
+			}
+		}
+		migration.Phase = goharborv1.StorageMigrationSwitching
+	}
+
+	return nil
+}
+
+// getMigrationTargets returns the in-cluster minIO migrated from, and the external storage migrated to.
+// The minIO is accessed with the root credentials.
+func (m *MinIOReconciler) getMigrationTargets() (*s3Target, *s3Target, error) {
+	sourceTarget, err := m.getInstanceTarget()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	target, err := m.getS3Target()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sourceTarget, target, nil
+}
+
+// getInstanceTarget returns the minIO instance accessed with the root credentials.
+func (m *MinIOReconciler) getInstanceTarget() (*s3Target, error) {
+	accessKey, secretKey, err := m.getCredsFromSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Target{
+		Endpoint:  m.getMinIOEndpoint(),
+		AccessKey: string(accessKey),
+		SecretKey: string(secretKey),
+		Region:    DefaultRegion,
+	}, nil
+}
+
+// getMigrationBuckets returns the buckets to migrate with their size. The registry bucket is copied to the
+// root directory of the external bucket, and the chartmuseum bucket to the chartmuseum directory under it.
+func getMigrationBuckets(source, target *s3Target) ([]goharborv1.BucketMigrationStatus, error) {
+	client, err := source.newClient()
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := []goharborv1.BucketMigrationStatus{
+		{Source: DefaultBucket, Target: target.Bucket, Prefix: target.getObjectKey("")},
+	}
+
+	exists, err := client.BucketExists(DefaultChartMuseumBucket)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		buckets = append(buckets, goharborv1.BucketMigrationStatus{
+			Source: DefaultChartMuseumBucket,
+			Target: target.Bucket,
+			Prefix: target.getObjectKey(chartMuseumDirectory),
+		})
+	}
+
+	return countMigrationObjects(client, buckets)
+}
+
+// countMigrationObjects sets the number and the size of the objects to copy from the source buckets.
+func countMigrationObjects(client *minv6.Client, buckets []goharborv1.BucketMigrationStatus) ([]goharborv1.BucketMigrationStatus, error) {
+	for i := range buckets {
+		doneCh := make(chan struct{})
+		for object := range client.ListObjectsV2(buckets[i].Source, "", true, doneCh) {
+			if object.Err != nil {
+				close(doneCh)
+				return nil, object.Err
+			}
+			buckets[i].TotalObjects++
+			buckets[i].TotalBytes += object.Size
+		}
+		close(doneCh)
+	}
+
+	return buckets, nil
+}
+
+// copyObjects copies the objects of the buckets until all are copied or the deadline is reached.
+func (m *MinIOReconciler) copyObjects(source, target *s3Target, buckets []goharborv1.BucketMigrationStatus, deadline time.Time) (bool, error) {
+	sourceClient, err := source.newClient()
+	if err != nil {
+		return false, err
+	}
+	targetClient, err := target.newClient()
+	if err != nil {
+		return false, err
+	}
+
+	for i := range buckets {
+		bucket := &buckets[i]
+		for !bucket.Done {
+			result, err := source.listObjectsAfter(m.Ctx, bucket.Source, "", bucket.LastKey)
+			if err != nil {
+				return false, err
+			}
+
+			for _, object := range result.Contents {
+				if time.Now().After(deadline) {
+					return false, nil
+				}
+
+				targetKey := joinObjectKey(bucket.Prefix, object.Key)
+				err := copyObject(m.Ctx, sourceClient, targetClient, bucket.Source, bucket.Target, targetKey, object)
+				if err != nil {
+					return false, err
+				}
+				bucket.LastKey = object.Key
+				bucket.CopiedObjects++
+				bucket.CopiedBytes += object.Size
+			}
+
+			if !result.IsTruncated {
+				bucket.Done = true
+			}
+		}
+	}
+
+	return true, nil
+}
+
+func copyObject(ctx context.Context, source, target *minv6.Client, sourceBucket, targetBucket, targetKey string, object minv6.ObjectInfo) error {
+	reader, err := source.GetObjectWithContext(ctx, sourceBucket, object.Key, minv6.GetObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("read %s/%s: %w", sourceBucket, object.Key, err)
+	}
+	defer reader.Close()
+
+	_, err = target.PutObjectWithContext(ctx, targetBucket, targetKey, reader, object.Size, minv6.PutObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("write %s/%s: %w", targetBucket, targetKey, err)
+	}
+	return nil
+}
+
+// verifyObjects check every object of the source bucket exists in the target bucket with the same size.
+// Both buckets are listed in lexicographical order, the objects of the target bucket which are not migrated are skipped.
+func verifyObjects(source, target *s3Target, bucket *goharborv1.BucketMigrationStatus) error {
+	sourceClient, err := source.newClient()
+	if err != nil {
+		return err
+	}
+	targetClient, err := target.newClient()
+	if err != nil {
+		return err
+	}
+
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	listPrefix := bucket.Prefix
+	if listPrefix != "" {
+		listPrefix += "/"
+	}
+	targetObjects := targetClient.ListObjectsV2(bucket.Target, listPrefix, true, doneCh)
+	targetObject, ok := <-targetObjects
+
+	for sourceObject := range sourceClient.ListObjectsV2(bucket.Source, "", true, doneCh) {
+		if sourceObject.Err != nil {
+			return sourceObject.Err
+		}
+
+		key := joinObjectKey(bucket.Prefix, sourceObject.Key)
+		for ok && targetObject.Err == nil && targetObject.Key < key {
+			targetObject, ok = <-targetObjects
+		}
+		if ok && targetObject.Err != nil {
+			return targetObject.Err
+		}
+
+		if !ok || targetObject.Key != key {
+			return fmt.Errorf("object %s/%s is not copied to %s/%s", bucket.Source, sourceObject.Key, bucket.Target, key)
+		}
+		if targetObject.Size != sourceObject.Size {
+			return fmt.Errorf("object %s/%s is copied with size %d instead of %d", bucket.Target, key, targetObject.Size, sourceObject.Size)
+		}
+	}
+
+	return nil
+}
+
+func joinObjectKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "/" + key
+}
+
+// listObjectsAfter lists a page of the objects with the prefix after the key startAfter in lexicographical order.
+// It is used to resume the copy of a bucket, which is not supported by the list API of minio-go.
+func (t *s3Target) listObjectsAfter(ctx context.Context, bucket, prefix, startAfter string) (*minv6.ListBucketV2Result, error) {
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("max-keys", strconv.Itoa(listObjectsMaxKeys))
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+	if startAfter != "" {
+		query.Set("start-after", startAfter)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, t.getURL()+"/"+bucket+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	req = signer.SignV4(*req, t.AccessKey, t.SecretKey, "", t.Region)
+
+	transport, err := t.newTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := (&http.Client{Transport: transport}).Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("list objects of %s: %s %s", bucket, resp.Status, string(message))
+	}
+
+	var result minv6.ListBucketV2Result
+	err = xml.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// minioMigratingStatus reports the progress of the storage migration.
+func minioMigratingStatus(migration *goharborv1.StorageMigrationStatus) *lcm.CRStatus {
+	var copiedObjects, totalObjects, copiedBytes, totalBytes int64
+	for _, bucket := range migration.Buckets {
+		copiedObjects += bucket.CopiedObjects
+		totalObjects += bucket.TotalObjects
+		copiedBytes += bucket.CopiedBytes
+		totalBytes += bucket.TotalBytes
+	}
+
+	source, target := getMigrationEnds(migration)
+
+	status := minioUnknownStatus()
+	status.Condition.Reason = MigratingStorage
+	status.Condition.Message = fmt.Sprintf("Migrating storage from %s to %s, phase %s, copied %d/%d objects (%d/%d bytes)",
+		source, target, migration.Phase, copiedObjects, totalObjects, copiedBytes, totalBytes)
+	return status
+}
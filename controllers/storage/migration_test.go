@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"testing"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+)
+
+func TestGetPreviousReadOnly(t *testing.T) {
+	readOnly, writable := true, false
+
+	tests := []struct {
+		name      string
+		migration *goharborv1.StorageMigrationStatus
+		want      bool
+	}{
+		{
+			name:      "not recorded",
+			migration: &goharborv1.StorageMigrationStatus{Phase: goharborv1.StorageMigrationSwitching},
+			want:      false,
+		},
+		{
+			name:      "writable before the migration",
+			migration: &goharborv1.StorageMigrationStatus{Phase: goharborv1.StorageMigrationSwitching, PreviousReadOnly: &writable},
+			want:      false,
+		},
+		{
+			name:      "read-only before the migration",
+			migration: &goharborv1.StorageMigrationStatus{Phase: goharborv1.StorageMigrationSwitching, PreviousReadOnly: &readOnly},
+			want:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getPreviousReadOnly(tt.migration); got != tt.want {
+				t.Errorf("getPreviousReadOnly() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
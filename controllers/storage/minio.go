@@ -13,10 +13,14 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	k8serror "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -28,7 +32,7 @@ const (
 	ossStorage       = "oss"
 
 	DefaultExternalSecretSuffix = "harbor-cluster-storage"
-	DefaultCredsSecret          = "minio-creds"
+	DefaultCredsSecretSuffix    = "creds"
 	ExternalStorageSecretSuffix = "Secret"
 
 	DefaultZone   = "zone-harbor"
@@ -36,6 +40,8 @@ const (
 	DefaultRegion = "us-east-1"
 	DefaultBucket = "harbor"
 
+	DefaultMinIOPort = 9000
+
 	LabelOfStorageType = "storageType"
 )
 
@@ -51,6 +57,12 @@ type MinIOReconciler struct {
 	CurrentExternalSecret *corev1.Secret
 	DesiredExternalSecret *corev1.Secret
 	MinioClient           Minio
+
+	// AllowedSecretNamespaces are the namespaces which can be referenced by storage secret references.
+	AllowedSecretNamespaces []string
+
+	// instanceName is the name of the minIO instance serving harbor, see resolveMinIOInstance.
+	instanceName string
 }
 
 var (
@@ -63,7 +75,33 @@ var (
 
 // Reconciler implements the reconcile logic of minIO service
 func (m *MinIOReconciler) Reconcile() (*lcm.CRStatus, error) {
+	status, err := m.reconcileStorage()
+	if err != nil {
+		return status, err
+	}
+	return m.reconcileChartMuseumStorage(status)
+}
+
+// reconcileStorage reconciles the storage of the registry, which is shared by chartmuseum without a separate storage.
+func (m *MinIOReconciler) reconcileStorage() (*lcm.CRStatus, error) {
 	var minioCR minio.MinIOInstance
+	if getReplication(m.HarborCluster) == nil {
+		m.HarborCluster.Status.StorageReplication = nil
+	}
+
+	err := m.resolveMinIOInstance()
+	if err != nil {
+		return minioNotReadyStatus(GetMinIOError, err.Error()), err
+	}
+
+	source, err := m.getMigrationSource()
+	if err != nil {
+		return minioNotReadyStatus(GetMinIOError, err.Error()), err
+	}
+	if source != nil {
+		return m.ReconcileMigration(source)
+	}
+
 	if m.HarborCluster.Spec.Storage.Kind != inClusterStorage {
 		var exSecret corev1.Secret
 		err := m.KubeClient.Get(m.getExternalSecretNamespacedName(), &exSecret)
@@ -76,19 +114,29 @@ func (m *MinIOReconciler) Reconcile() (*lcm.CRStatus, error) {
 		m.CurrentExternalSecret = &exSecret
 		m.DesiredExternalSecret, err = m.generateExternalSecret()
 		if err != nil {
-			return minioNotReadyStatus(err.Error(), err.Error()), err
+			return minioNotReadyStatus(GetExternalCredentialError, err.Error()), err
 		}
 
 		if m.checkExternalUpdate() {
 			return m.ExternalUpdate()
 		}
 
-		return nil, nil
+		err = m.applyExternalChartMuseumSecret()
+		if err != nil {
+			return minioNotReadyStatus(CreateExternalSecretError, err.Error()), err
+		}
+
+		err = m.ensureExternalBucket()
+		if err != nil {
+			return minioNotReadyStatus(CreateExternalBucketError, err.Error()), err
+		}
+
+		return m.externalReadyStatus(), nil
 	}
 
 	m.DesiredMinIOCR = m.generateMinIOCR()
 
-	err := m.KubeClient.Get(m.getMinIONamespacedName(), &minioCR)
+	err = m.KubeClient.Get(m.getMinIONamespacedName(), &minioCR)
 	if k8serror.IsNotFound(err) {
 		return m.Provision()
 	} else if err != nil {
@@ -97,12 +145,15 @@ func (m *MinIOReconciler) Reconcile() (*lcm.CRStatus, error) {
 
 	m.CurrentMinIOCR = &minioCR
 
-	// TODO remove scale event
-	isScale, err := m.checkMinIOScale()
+	if m.isDistributedMigrationNeeded() {
+		return m.ReconcileDistributedMigration()
+	}
+
+	isExpansion, err := m.checkMinIOExpansion()
 	if err != nil {
-		return minioNotReadyStatus(ScaleMinIOError, err.Error()), err
+		return minioNotReadyStatus(ScaleMinIOError, err.Error()), nil
 	}
-	if isScale {
+	if isExpansion {
 		return m.Scale()
 	}
 
@@ -110,20 +161,54 @@ func (m *MinIOReconciler) Reconcile() (*lcm.CRStatus, error) {
 		return m.Update()
 	}
 
-	isReady, err := m.checkMinIOReady()
+	isReady, isServing, zonesMessage, err := m.checkMinIOReady()
 	if err != nil {
 		return minioNotReadyStatus(GetMinIOError, err.Error()), err
 	}
 
-	if isReady {
+	// minIO is ready as long as every erasure set keeps its write quorum, the pods being
+	// ready does not mean that the drives are online.
+	if isServing {
 		err := m.minioInit()
 		if err != nil {
 			return minioNotReadyStatus(CreateDefaultBucketError, err.Error()), err
 		}
-		return m.ProvisionInClusterSecretAsS3(&minioCR)
+
+		health, err := m.checkMinIOHealth()
+		if err != nil {
+			if !isReady {
+				return minioZonesNotReadyStatus(zonesMessage), nil
+			}
+			return minioNotReadyStatus(GetMinIOHealthError, err.Error()), nil
+		}
+		if health.QuorumLost {
+			return minioNotReadyStatus(MinIOQuorumLost, zonesMessage+"; "+health.String()), nil
+		}
+		if !isReady && !health.isDegraded() {
+			return minioZonesNotReadyStatus(zonesMessage), nil
+		}
+
+		err = m.ensureMinIOUsers(&minioCR)
+		if err != nil {
+			return minioNotReadyStatus(CreateMinIOUserError, err.Error()), err
+		}
+		status, err := m.ProvisionInClusterSecretAsS3(&minioCR)
+		if err != nil {
+			return status, err
+		}
+
+		m.reportStorageUsage()
+		m.reconcileReplication()
+
+		status.Condition.Message = zonesMessage + "; " + health.String()
+		if health.isDegraded() {
+			m.Log.Info("MinIO is degraded", "health", health.String())
+			status.Condition.Reason = StorageDegraded
+		}
+		return status, nil
 	}
 
-	return minioUnknownStatus(), nil
+	return minioZonesNotReadyStatus(zonesMessage), nil
 }
 
 func (m *MinIOReconciler) minioInit() error {
@@ -131,19 +216,8 @@ func (m *MinIOReconciler) minioInit() error {
 	if err != nil {
 		return err
 	}
-	endpoint := m.getServiceName() + "." + m.HarborCluster.Namespace
 
-	m.MinioClient, err = GetMinioClient(endpoint, string(accessKey), string(secretKey), DefaultRegion, false)
-	if err != nil {
-		return err
-	}
-
-	exists, err := m.MinioClient.IsBucketExists(DefaultBucket)
-	if err != nil || exists {
-		return err
-	}
-
-	err = m.MinioClient.CreateBucket(DefaultBucket)
+	m.MinioClient, err = GetMinioClient(m.getMinIOEndpoint(), string(accessKey), string(secretKey), DefaultRegion, false)
 	return err
 }
 
@@ -152,6 +226,10 @@ func (m *MinIOReconciler) checkMinIOUpdate() bool {
 		return true
 	}
 
+	if !cmp.Equal(m.DesiredMinIOCR.Spec.Env, m.CurrentMinIOCR.Spec.Env) {
+		return true
+	}
+
 	return false
 }
 
@@ -159,34 +237,84 @@ func (m *MinIOReconciler) checkExternalUpdate() bool {
 	return !cmp.Equal(m.DesiredExternalSecret.DeepCopy().Data, m.CurrentExternalSecret.DeepCopy().Data)
 }
 
-func (m *MinIOReconciler) checkMinIOScale() (bool, error) {
-	currentReplicas := m.CurrentMinIOCR.Spec.Zones[0].Servers
-	desiredReplicas := m.HarborCluster.Spec.Storage.InCluster.Spec.Replicas
-	if currentReplicas == desiredReplicas {
-		return false, nil
-	} else if currentReplicas == 1 {
-		return false, fmt.Errorf("not support upgrading from standalone to distributed mode")
+// checkMinIOExpansion check whether pools are appended. The existing zones of minIO can never be changed,
+// since minIO does not support resizing an existing erasure set.
+func (m *MinIOReconciler) checkMinIOExpansion() (bool, error) {
+	currentZones := m.CurrentMinIOCR.Spec.Zones
+	desiredZones := m.DesiredMinIOCR.Spec.Zones
+	if len(desiredZones) < len(currentZones) {
+		return false, fmt.Errorf("removing minIO pools is not supported")
 	}
 
-	// MinIO creates erasure-coding sets of 4 to 16 drives per set.
-	// The number of drives you provide in total must be a multiple of one of those numbers.
-	// TODO validate by webhook
-	if desiredReplicas%2 == 0 && desiredReplicas < 16 {
-		return true, nil
+	for i := range currentZones {
+		if currentZones[i] != desiredZones[i] {
+			return false, fmt.Errorf("changing servers of the existing minIO pool %s is not supported", currentZones[i].Name)
+		}
 	}
 
-	return false, fmt.Errorf("for distributed mode, supply 4 to 16 drives (should be even)")
+	return len(desiredZones) > len(currentZones), nil
 }
 
-func (m *MinIOReconciler) checkMinIOReady() (bool, error) {
+// checkMinIOReady check whether all servers of every zone are ready, and whether at least one server is ready to
+// serve requests. The readiness of zones is returned as message.
+// The servers of a zone are the consecutive ordinals of the minIO statefulset.
+func (m *MinIOReconciler) checkMinIOReady() (bool, bool, string, error) {
 	var minioStatefulSet appsv1.StatefulSet
 	err := m.KubeClient.Get(m.getMinIONamespacedName(), &minioStatefulSet)
+	if err != nil {
+		return false, false, "", err
+	}
+
+	opts := &client.ListOptions{
+		Namespace:     m.HarborCluster.Namespace,
+		LabelSelector: labels.SelectorFromSet(minioStatefulSet.Spec.Selector.MatchLabels),
+	}
+	var pods corev1.PodList
+	err = m.KubeClient.List(opts, &pods)
+	if err != nil {
+		return false, false, "", err
+	}
 
-	if minioStatefulSet.Status.ReadyReplicas == m.HarborCluster.Spec.Storage.InCluster.Spec.Replicas {
-		return true, err
+	readyOrdinals := make(map[int]bool)
+	for _, pod := range pods.Items {
+		ordinal, err := strconv.Atoi(strings.TrimPrefix(pod.Name, minioStatefulSet.Name+"-"))
+		if err != nil || pod.DeletionTimestamp != nil {
+			continue
+		}
+		readyOrdinals[ordinal] = isPodReady(&pod)
 	}
 
-	return false, err
+	isReady, isServing := true, false
+	messages := make([]string, 0, len(m.CurrentMinIOCR.Spec.Zones))
+	start := 0
+	for _, zone := range m.CurrentMinIOCR.Spec.Zones {
+		ready := 0
+		for ordinal := start; ordinal < start+int(zone.Servers); ordinal++ {
+			if readyOrdinals[ordinal] {
+				ready++
+			}
+		}
+		start += int(zone.Servers)
+
+		if ready != int(zone.Servers) {
+			isReady = false
+		}
+		if ready > 0 {
+			isServing = true
+		}
+		messages = append(messages, fmt.Sprintf("%s: %d/%d ready", zone.Name, ready, zone.Servers))
+	}
+
+	return isReady, isServing, strings.Join(messages, ", "), nil
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
 }
 
 func (m *MinIOReconciler) getMinIONamespacedName() types.NamespacedName {
@@ -196,10 +324,20 @@ func (m *MinIOReconciler) getMinIONamespacedName() types.NamespacedName {
 	}
 }
 
+// getMinIOEndpoint returns the host and port of the minIO service.
+func (m *MinIOReconciler) getMinIOEndpoint() string {
+	return fmt.Sprintf("%s.%s:%d", m.getServiceName(), m.HarborCluster.Namespace, DefaultMinIOPort)
+}
+
+// getMinIOEndpointURL returns the minIO service URL used by harbor.
+func (m *MinIOReconciler) getMinIOEndpointURL() string {
+	return "http://" + m.getMinIOEndpoint()
+}
+
 func (m *MinIOReconciler) getMinIOSecretNamespacedName() types.NamespacedName {
 	return types.NamespacedName{
 		Namespace: m.HarborCluster.Namespace,
-		Name:      m.HarborCluster.Name + "-" + DefaultCredsSecret,
+		Name:      m.getServiceName() + "-" + DefaultCredsSecretSuffix,
 	}
 }
 
@@ -210,6 +348,18 @@ func (m *MinIOReconciler) getExternalSecretNamespacedName() types.NamespacedName
 	}
 }
 
+func (m *MinIOReconciler) getExternalProperties() *lcm.Properties {
+	p := &lcm.Property{
+		Name:  m.HarborCluster.Spec.Storage.Kind + ExternalStorageSecretSuffix,
+		Value: m.getExternalSecretName(),
+	}
+	properties := &lcm.Properties{p}
+	if m.isExternalChartMuseumSecretNeeded() {
+		properties.Add(lcm.ExternalChartMuseumSecretForStorage, m.getExternalChartMuseumSecretName())
+	}
+	return properties
+}
+
 func (m *MinIOReconciler) getExternalSecretName() string {
 	return m.HarborCluster.Name + "-" + DefaultExternalSecretSuffix
 }
@@ -240,6 +390,13 @@ func minioUnknownStatus() *lcm.CRStatus {
 	}
 }
 
+func minioZonesNotReadyStatus(zonesMessage string) *lcm.CRStatus {
+	status := minioUnknownStatus()
+	status.Condition.Reason = MinIOZonesNotReady
+	status.Condition.Message = zonesMessage
+	return status
+}
+
 func minioReadyStatus(properties *lcm.Properties) *lcm.CRStatus {
 	return &lcm.CRStatus{
 		Condition: goharborv1.HarborClusterCondition{
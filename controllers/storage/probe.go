@@ -0,0 +1,415 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	"github.com/goharbor/harbor-cluster-operator/lcm"
+	minv6 "github.com/minio/minio-go/v6"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	DefaultS3Endpoint     = "s3.amazonaws.com"
+	DefaultAzureRealm     = "core.windows.net"
+	DefaultGcsEndpoint    = "https://storage.googleapis.com"
+	DefaultOssEndpointFmt = "%s.aliyuncs.com"
+
+	// probeObject is written, read and deleted in the bucket to check the permissions of the credentials.
+	probeObject  = ".harbor-cluster-probe"
+	probeTimeout = 10 * time.Second
+	// probeInterval is the interval between two checks of a bucket with an object,
+	// only the endpoint is checked in between unless the bucket or its credentials change.
+	probeInterval = time.Hour
+)
+
+// storageProbeError is a failed check of the external storage, the reason is reported in the StorageReady condition.
+type storageProbeError struct {
+	Reason string
+	Err    error
+}
+
+func (e *storageProbeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *storageProbeError) Unwrap() error {
+	return e.Err
+}
+
+// externalReadyStatus reports the external storage ready only if it passes the checks,
+// otherwise the reason of the failed check is reported.
+func (m *MinIOReconciler) externalReadyStatus() *lcm.CRStatus {
+	err := m.probeExternalStorage()
+	if err != nil {
+		reason := getProbeReason(err)
+		m.Log.Info("External storage check failed", "reason", reason, "error", err.Error())
+		return minioNotReadyStatus(reason, err.Error())
+	}
+
+	m.reportStorageUsage()
+	return minioReadyStatus(m.getExternalProperties())
+}
+
+// probeExternalStorage checks the external storage can be used by harbor with the given credentials.
+// For s3 and oss, it verifies the endpoint is reachable, the bucket exists, and an object can be written,
+// read and deleted. The other kinds are only checked to be reachable.
+func (m *MinIOReconciler) probeExternalStorage() error {
+	ctx, cancel := context.WithTimeout(m.Ctx, probeTimeout)
+	defer cancel()
+
+	switch m.HarborCluster.Spec.Storage.Kind {
+	case s3Storage, ossStorage:
+		target, err := m.getS3Target()
+		if err != nil {
+			return &storageProbeError{Reason: GetExternalCredentialError, Err: err}
+		}
+		return m.probeS3Target(ctx, target)
+	case azureStorage:
+		return probeEndpoint(ctx, m.getAzureEndpoint(), false)
+	case gcsStorage:
+		return probeEndpoint(ctx, DefaultGcsEndpoint, false)
+	case swiftStorage:
+		return probeEndpoint(ctx, m.HarborCluster.Spec.Storage.Swift.Authurl, m.HarborCluster.Spec.Storage.Swift.InsecureSkipVerify)
+	default:
+		return &storageProbeError{Reason: NotSupportType, Err: fmt.Errorf(NotSupportType)}
+	}
+}
+
+// s3Target is the bucket of the s3 compatible external storage.
+type s3Target struct {
+	Endpoint      string
+	AccessKey     string
+	SecretKey     string
+	Secure        bool
+	Region        string
+	Bucket        string
+	RootDirectory string
+	// CACert is trusted in addition to the system CAs if it is set.
+	CACert []byte
+}
+
+// getS3Target returns the bucket of the s3 or oss storage, and the credentials to access it.
+func (m *MinIOReconciler) getS3Target() (*s3Target, error) {
+	storage := m.HarborCluster.Spec.Storage
+	target, err := m.getS3TargetOf(storage.Kind, storage.S3, storage.Oss)
+	if err != nil {
+		return nil, err
+	}
+	target.RootDirectory = m.getExternalRootDirectory(target.RootDirectory)
+	return target, nil
+}
+
+// getS3TargetOf returns the bucket of the s3 or oss options, and the credentials to access it.
+func (m *MinIOReconciler) getS3TargetOf(kind string, s3 *goharborv1.S3, oss *goharborv1.Oss) (*s3Target, error) {
+	switch kind {
+	case s3Storage:
+		accessKey, err := m.getCredential(s3.AccessKey, s3.AccessKeyRef)
+		if err != nil {
+			return nil, err
+		}
+		secretKey, err := m.getCredential(s3.SecretKey, s3.SecretKeyRef)
+		if err != nil {
+			return nil, err
+		}
+
+		endpoint, secure, err := parseEndpoint(s3.RegionEndpoint, s3.Secure)
+		if err != nil {
+			return nil, err
+		}
+		if endpoint == "" {
+			endpoint = DefaultS3Endpoint
+		}
+
+		return &s3Target{
+			Endpoint:      endpoint,
+			AccessKey:     accessKey,
+			SecretKey:     secretKey,
+			Secure:        secure,
+			Region:        s3.Region,
+			Bucket:        s3.Bucket,
+			RootDirectory: s3.RootDirectory,
+		}, nil
+	case ossStorage:
+		secretKey, err := m.getCredential(oss.AccessKeySecret, oss.AccessKeySecretRef)
+		if err != nil {
+			return nil, err
+		}
+
+		// the registry connects with TLS unless secure is explicitly disabled.
+		secure := true
+		if oss.Secure != "" {
+			secure, _ = strconv.ParseBool(oss.Secure)
+		}
+
+		endpoint, secure, err := parseEndpoint(oss.Endpoint, secure)
+		if err != nil {
+			return nil, err
+		}
+		if endpoint == "" {
+			region := oss.Region
+			if internal, _ := strconv.ParseBool(oss.Internal); internal {
+				region += "-internal"
+			}
+			endpoint = fmt.Sprintf(DefaultOssEndpointFmt, region)
+		}
+
+		return &s3Target{
+			Endpoint:      endpoint,
+			AccessKey:     oss.AccessKeyId,
+			SecretKey:     secretKey,
+			Secure:        secure,
+			Region:        oss.Region,
+			Bucket:        oss.Bucket,
+			RootDirectory: oss.RootDirectory,
+		}, nil
+	default:
+		return nil, fmt.Errorf("storage kind %s is not s3 compatible", kind)
+	}
+}
+
+// getObjectKey returns the key of the object under the root directory, which has no leading slash in object keys.
+func (t *s3Target) getObjectKey(name string) string {
+	return path.Join(strings.TrimPrefix(t.RootDirectory, "/"), name)
+}
+
+func (m *MinIOReconciler) getAzureEndpoint() string {
+	return getAzureEndpointOf(m.HarborCluster.Spec.Storage.Azure)
+}
+
+func getAzureEndpointOf(azure *goharborv1.Azure) string {
+	realm := azure.Realm
+	if realm == "" {
+		realm = DefaultAzureRealm
+	}
+	return fmt.Sprintf("https://%s.blob.%s", azure.AccountName, realm)
+}
+
+func (t *s3Target) getURL() string {
+	if t.Secure {
+		return "https://" + t.Endpoint
+	}
+	return "http://" + t.Endpoint
+}
+
+func (t *s3Target) newClient() (*minv6.Client, error) {
+	client, err := minv6.NewWithRegion(t.Endpoint, t.AccessKey, t.SecretKey, t.Secure, t.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Secure && len(t.CACert) > 0 {
+		transport, err := newTransportWithCA(t.CACert)
+		if err != nil {
+			return nil, err
+		}
+		client.SetCustomTransport(transport)
+	}
+	return client, nil
+}
+
+func (t *s3Target) newTransport() (http.RoundTripper, error) {
+	if t.Secure && len(t.CACert) > 0 {
+		return newTransportWithCA(t.CACert)
+	}
+	return http.DefaultTransport, nil
+}
+
+// parseEndpoint returns the host of the endpoint, and whether TLS is used according to its scheme.
+func parseEndpoint(endpoint string, secure bool) (string, bool, error) {
+	if !strings.Contains(endpoint, "://") {
+		return endpoint, secure, nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", false, err
+	}
+	return u.Host, u.Scheme == "https", nil
+}
+
+// probeS3Target checks the bucket with an object if it is due, otherwise only the endpoint is checked.
+// The successful checks are recorded in status, so that the objects are not written on every reconciliation.
+func (m *MinIOReconciler) probeS3Target(ctx context.Context, target *s3Target) error {
+	checksum := target.getChecksum()
+	now := time.Now()
+	if !isBucketProbeDue(m.HarborCluster.Status.StorageProbes, checksum, now) {
+		return probeEndpoint(ctx, target.getURL(), false)
+	}
+
+	err := probeBucket(ctx, target)
+	if err != nil {
+		return err
+	}
+	m.HarborCluster.Status.StorageProbes = recordBucketProbe(m.HarborCluster.Status.StorageProbes, checksum, now)
+	return nil
+}
+
+// isBucketProbeDue check whether the bucket is never checked with its credentials, or the probe interval has elapsed.
+func isBucketProbeDue(probes []goharborv1.StorageProbeStatus, checksum string, now time.Time) bool {
+	for _, probe := range probes {
+		if probe.Checksum == checksum {
+			return !now.Before(probe.LastProbeTime.Add(probeInterval))
+		}
+	}
+	return true
+}
+
+// recordBucketProbe records the successful check of the bucket,
+// the records which are due are dropped as the bucket or its credentials are changed.
+func recordBucketProbe(probes []goharborv1.StorageProbeStatus, checksum string, now time.Time) []goharborv1.StorageProbeStatus {
+	recorded := []goharborv1.StorageProbeStatus{{Checksum: checksum, LastProbeTime: metav1.NewTime(now)}}
+	for _, probe := range probes {
+		if probe.Checksum != checksum && !isBucketProbeDue(probes, probe.Checksum, now) {
+			recorded = append(recorded, probe)
+		}
+	}
+	return recorded
+}
+
+// getChecksum returns the checksum of the bucket and its credentials, the credentials are not kept in status.
+func (t *s3Target) getChecksum() string {
+	hash := sha256.New()
+	for _, value := range []string{t.Endpoint, t.AccessKey, t.SecretKey, strconv.FormatBool(t.Secure), t.Region, t.Bucket, t.RootDirectory, string(t.CACert)} {
+		hash.Write([]byte(value))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// probeBucket checks the bucket with the S3 API.
+func probeBucket(ctx context.Context, target *s3Target) error {
+	// the S3 client retries on connection errors until the context expires, which hides the cause,
+	// so the endpoint is checked with a single request at first.
+	err := probeEndpoint(ctx, target.getURL(), false)
+	if err != nil {
+		return err
+	}
+
+	client, err := target.newClient()
+	if err != nil {
+		return &storageProbeError{Reason: StorageUnreachableError, Err: err}
+	}
+
+	bucket := target.Bucket
+	exists, err := client.BucketExistsWithContext(ctx, bucket)
+	if err != nil {
+		return newStorageProbeError(err, StorageAuthFailedError)
+	}
+	if !exists {
+		return &storageProbeError{Reason: StorageBucketMissingError, Err: fmt.Errorf("bucket %s does not exist", bucket)}
+	}
+
+	object := target.getObjectKey(probeObject)
+	content := []byte(strconv.FormatInt(time.Now().Unix(), 10))
+
+	_, err = client.PutObjectWithContext(ctx, bucket, object, bytes.NewReader(content), int64(len(content)), minv6.PutObjectOptions{})
+	if err != nil {
+		return newStorageProbeError(fmt.Errorf("write %s: %w", object, err), StoragePermissionDeniedError)
+	}
+
+	reader, err := client.GetObjectWithContext(ctx, bucket, object, minv6.GetObjectOptions{})
+	if err != nil {
+		return newStorageProbeError(fmt.Errorf("read %s: %w", object, err), StoragePermissionDeniedError)
+	}
+	defer reader.Close()
+
+	read, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return newStorageProbeError(fmt.Errorf("read %s: %w", object, err), StoragePermissionDeniedError)
+	}
+	if !bytes.Equal(read, content) {
+		return &storageProbeError{Reason: StorageProbeError, Err: fmt.Errorf("the content of %s read back does not match", object)}
+	}
+
+	err = client.RemoveObject(bucket, object)
+	if err != nil {
+		return newStorageProbeError(fmt.Errorf("delete %s: %w", object, err), StoragePermissionDeniedError)
+	}
+
+	return nil
+}
+
+// probeEndpoint checks the endpoint is reachable, any HTTP response is accepted.
+func probeEndpoint(ctx context.Context, endpoint string, insecureSkipVerify bool) error {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return &storageProbeError{Reason: StorageUnreachableError, Err: err}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+
+	resp, err := (&http.Client{Transport: transport}).Do(req.WithContext(ctx))
+	if err != nil {
+		return newStorageProbeError(err, StorageProbeError)
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// newStorageProbeError classifies the error of a storage request,
+// a rejected request which is not caused by the credentials gets the reason deniedReason.
+func newStorageProbeError(err error, deniedReason string) error {
+	if isTLSError(err) {
+		return &storageProbeError{Reason: StorageTLSError, Err: err}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return &storageProbeError{Reason: StorageUnreachableError, Err: err}
+	}
+
+	var errResp minv6.ErrorResponse
+	if errors.As(err, &errResp) {
+		switch errResp.Code {
+		case "InvalidAccessKeyId", "SignatureDoesNotMatch", "InvalidToken", "ExpiredToken":
+			return &storageProbeError{Reason: StorageAuthFailedError, Err: err}
+		case "AccessDenied":
+			return &storageProbeError{Reason: deniedReason, Err: err}
+		case "NoSuchBucket":
+			return &storageProbeError{Reason: StorageBucketMissingError, Err: err}
+		}
+	}
+
+	return &storageProbeError{Reason: StorageProbeError, Err: err}
+}
+
+func isTLSError(err error) bool {
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certificateInvalidErr x509.CertificateInvalidError
+	var recordHeaderErr tls.RecordHeaderError
+
+	return errors.As(err, &unknownAuthorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &certificateInvalidErr) ||
+		errors.As(err, &recordHeaderErr)
+}
+
+// getProbeReason returns the reason of the failed storage check.
+func getProbeReason(err error) string {
+	var probeErr *storageProbeError
+	if errors.As(err, &probeErr) {
+		return probeErr.Reason
+	}
+	return StorageProbeError
+}
@@ -0,0 +1,433 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeS3Server is a local stand-in of minIO, which serves the S3 requests of the bucket probe,
+// and the listing of the objects copied by the migration and the replication.
+type fakeS3Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	buckets map[string]map[string][]byte
+	// modified keeps the last modified time of the objects by bucket and key.
+	modified map[string]time.Time
+	// maxKeys limits the objects of a listing page if it is set.
+	maxKeys int
+	// errorCode is returned to every request on the bucket if it is set.
+	errorCode string
+	// deniedMethod is rejected with AccessDenied on the objects.
+	deniedMethod string
+	// objectRequests counts the requests on the objects.
+	objectRequests int
+}
+
+func newFakeS3Server(buckets ...string) *fakeS3Server {
+	s := &fakeS3Server{buckets: map[string]map[string][]byte{}, modified: map[string]time.Time{}}
+	for _, bucket := range buckets {
+		s.buckets[bucket] = map[string][]byte{}
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *fakeS3Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if parts[0] == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if s.errorCode != "" {
+		writeS3Error(w, r, http.StatusForbidden, s.errorCode)
+		return
+	}
+
+	objects, ok := s.buckets[parts[0]]
+	if !ok {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	if len(parts) == 1 || parts[1] == "" {
+		if r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2" {
+			s.listObjects(w, parts[0], r.URL.Query())
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	s.objectRequests++
+	if r.Method == s.deniedMethod {
+		writeS3Error(w, r, http.StatusForbidden, "AccessDenied")
+		return
+	}
+
+	key := parts[1]
+	switch r.Method {
+	case http.MethodPut:
+		content, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("X-Amz-Content-Sha256") == "STREAMING-AWS4-HMAC-SHA256-PAYLOAD" {
+			content = decodeAWSChunked(content)
+		}
+		objects[key] = content
+		s.modified[parts[0]+"/"+key] = time.Now().UTC()
+		w.Header().Set("ETag", `"probe"`)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		content, ok := objects[key]
+		if !ok {
+			writeS3Error(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", `"probe"`)
+		w.Header().Set("Last-Modified", s.modified[parts[0]+"/"+key].Format(http.TimeFormat))
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(content)
+		}
+	case http.MethodDelete:
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+type fakeListBucketResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Name                  string
+	Prefix                string
+	KeyCount              int
+	MaxKeys               int
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []fakeListObject
+}
+
+type fakeListObject struct {
+	Key          string
+	Size         int
+	LastModified string
+	ETag         string
+}
+
+// listObjects serves a page of the objects of the bucket in lexicographical order,
+// the continuation token is the key of the last listed object.
+func (s *fakeS3Server) listObjects(w http.ResponseWriter, bucket string, query url.Values) {
+	objects := s.buckets[bucket]
+	prefix := query.Get("prefix")
+	after := query.Get("start-after")
+	if token := query.Get("continuation-token"); token > after {
+		after = token
+	}
+	maxKeys := listObjectsMaxKeys
+	if value, err := strconv.Atoi(query.Get("max-keys")); err == nil && value > 0 {
+		maxKeys = value
+	}
+	if s.maxKeys > 0 && s.maxKeys < maxKeys {
+		maxKeys = s.maxKeys
+	}
+
+	var keys []string
+	for key := range objects {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	result := fakeListBucketResult{Name: bucket, Prefix: prefix, MaxKeys: maxKeys}
+	if len(keys) > maxKeys {
+		keys = keys[:maxKeys]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, key := range keys {
+		result.Contents = append(result.Contents, fakeListObject{
+			Key:          key,
+			Size:         len(objects[key]),
+			LastModified: s.modified[bucket+"/"+key].Format("2006-01-02T15:04:05.000Z"),
+			ETag:         `"probe"`,
+		})
+	}
+	result.KeyCount = len(result.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	xml.NewEncoder(w).Encode(result)
+}
+
+// putObject stores an object with its last modified time, bypassing the S3 API.
+func (s *fakeS3Server) putObject(bucket, key, content string, modified time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.buckets[bucket] == nil {
+		s.buckets[bucket] = map[string][]byte{}
+	}
+	s.buckets[bucket][key] = []byte(content)
+	s.modified[bucket+"/"+key] = modified.UTC()
+}
+
+// getObject returns the content of an object, and whether it exists.
+func (s *fakeS3Server) getObject(bucket, key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	content, ok := s.buckets[bucket][key]
+	return string(content), ok
+}
+
+// decodeAWSChunked returns the payload of the body signed by chunks, the signatures are not verified.
+func decodeAWSChunked(body []byte) []byte {
+	var payload []byte
+	for len(body) > 0 {
+		end := bytes.Index(body, []byte("\r\n"))
+		if end < 0 {
+			break
+		}
+		size, err := strconv.ParseInt(strings.SplitN(string(body[:end]), ";", 2)[0], 16, 64)
+		if err != nil || size == 0 {
+			break
+		}
+		body = body[end+2:]
+		payload = append(payload, body[:size]...)
+		body = bytes.TrimPrefix(body[size:], []byte("\r\n"))
+	}
+	return payload
+}
+
+func writeS3Error(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+	}
+}
+
+func (s *fakeS3Server) getTarget(bucket string) *s3Target {
+	return &s3Target{
+		Endpoint:      strings.TrimPrefix(s.URL, "http://"),
+		AccessKey:     "harbor",
+		SecretKey:     "harbor-secret",
+		Region:        "us-east-1",
+		Bucket:        bucket,
+		RootDirectory: "/harbor",
+	}
+}
+
+func TestProbeBucket(t *testing.T) {
+	tests := []struct {
+		name         string
+		bucket       string
+		errorCode    string
+		deniedMethod string
+		wantReason   string
+	}{
+		{
+			name:   "usable bucket",
+			bucket: DefaultBucket,
+		},
+		{
+			name:       "missing bucket",
+			bucket:     "missing",
+			wantReason: StorageBucketMissingError,
+		},
+		{
+			name:       "invalid access key",
+			bucket:     DefaultBucket,
+			errorCode:  "InvalidAccessKeyId",
+			wantReason: StorageAuthFailedError,
+		},
+		{
+			name:         "write denied",
+			bucket:       DefaultBucket,
+			deniedMethod: http.MethodPut,
+			wantReason:   StoragePermissionDeniedError,
+		},
+		{
+			name:         "delete denied",
+			bucket:       DefaultBucket,
+			deniedMethod: http.MethodDelete,
+			wantReason:   StoragePermissionDeniedError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeS3Server(DefaultBucket)
+			defer server.Close()
+			server.errorCode = tt.errorCode
+			server.deniedMethod = tt.deniedMethod
+
+			ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+			defer cancel()
+
+			err := probeBucket(ctx, server.getTarget(tt.bucket))
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Fatalf("probeBucket() error = %v", err)
+				}
+				if objects := server.buckets[DefaultBucket]; len(objects) != 0 && tt.deniedMethod == "" {
+					t.Errorf("probeBucket() left %d objects in the bucket", len(objects))
+				}
+				return
+			}
+			if got := getProbeReason(err); got != tt.wantReason {
+				t.Errorf("probeBucket() reason = %v, want %v, error = %v", got, tt.wantReason, err)
+			}
+		})
+	}
+}
+
+func TestProbeEndpoint(t *testing.T) {
+	server := newFakeS3Server()
+	url := server.URL
+	server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	err := probeEndpoint(ctx, url, false)
+	if got := getProbeReason(err); got != StorageUnreachableError {
+		t.Errorf("probeEndpoint() reason = %v, want %v, error = %v", got, StorageUnreachableError, err)
+	}
+}
+
+func TestProbeS3Target(t *testing.T) {
+	server := newFakeS3Server(DefaultBucket)
+	defer server.Close()
+
+	m := &MinIOReconciler{HarborCluster: &goharborv1.HarborCluster{}}
+	target := server.getTarget(DefaultBucket)
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	if err := m.probeS3Target(ctx, target); err != nil {
+		t.Fatalf("probeS3Target() error = %v", err)
+	}
+	if server.objectRequests == 0 {
+		t.Fatalf("probeS3Target() did not check the bucket with an object")
+	}
+	if got := len(m.HarborCluster.Status.StorageProbes); got != 1 {
+		t.Fatalf("probeS3Target() recorded %d probes, want 1", got)
+	}
+
+	server.objectRequests = 0
+	if err := m.probeS3Target(ctx, target); err != nil {
+		t.Fatalf("probeS3Target() error = %v", err)
+	}
+	if server.objectRequests != 0 {
+		t.Errorf("probeS3Target() checked the bucket with an object again before the probe interval")
+	}
+
+	target.SecretKey = "rotated-secret"
+	if err := m.probeS3Target(ctx, target); err != nil {
+		t.Fatalf("probeS3Target() error = %v", err)
+	}
+	if server.objectRequests == 0 {
+		t.Errorf("probeS3Target() did not check the bucket with an object after the credentials changed")
+	}
+}
+
+func TestIsBucketProbeDue(t *testing.T) {
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	probes := []goharborv1.StorageProbeStatus{
+		{Checksum: "recent", LastProbeTime: metav1.NewTime(now.Add(-time.Minute))},
+		{Checksum: "expired", LastProbeTime: metav1.NewTime(now.Add(-probeInterval))},
+	}
+
+	tests := []struct {
+		name     string
+		checksum string
+		want     bool
+	}{
+		{
+			name:     "recently checked",
+			checksum: "recent",
+			want:     false,
+		},
+		{
+			name:     "probe interval elapsed",
+			checksum: "expired",
+			want:     true,
+		},
+		{
+			name:     "never checked",
+			checksum: "changed",
+			want:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isBucketProbeDue(probes, tt.checksum, now); got != tt.want {
+				t.Errorf("isBucketProbeDue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordBucketProbe(t *testing.T) {
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	probes := []goharborv1.StorageProbeStatus{
+		{Checksum: "chartmuseum", LastProbeTime: metav1.NewTime(now.Add(-time.Minute))},
+		{Checksum: "registry", LastProbeTime: metav1.NewTime(now.Add(-probeInterval))},
+		{Checksum: "replaced", LastProbeTime: metav1.NewTime(now.Add(-2 * probeInterval))},
+	}
+
+	got := recordBucketProbe(probes, "registry", now)
+
+	want := map[string]time.Time{
+		"registry":    now,
+		"chartmuseum": now.Add(-time.Minute),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("recordBucketProbe() = %v, want %v", got, want)
+	}
+	for _, probe := range got {
+		if wantTime, ok := want[probe.Checksum]; !ok || !probe.LastProbeTime.Time.Equal(wantTime) {
+			t.Errorf("recordBucketProbe() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestS3TargetGetChecksum(t *testing.T) {
+	target := &s3Target{Endpoint: "s3.amazonaws.com", AccessKey: "ak", SecretKey: "sk", Bucket: "harbor"}
+	checksum := target.getChecksum()
+
+	if strings.Contains(checksum, target.SecretKey) {
+		t.Errorf("getChecksum() = %v, contains the secret key", checksum)
+	}
+
+	changed := *target
+	changed.SecretKey = "rotated"
+	if changed.getChecksum() == checksum {
+		t.Errorf("getChecksum() is not changed by the secret key")
+	}
+
+	shifted := *target
+	shifted.AccessKey, shifted.SecretKey = "a", "ksk"
+	if shifted.getChecksum() == checksum {
+		t.Errorf("getChecksum() is not changed by the boundary of the credentials")
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/goharbor/harbor-cluster-operator/controllers/common"
 	"github.com/goharbor/harbor-cluster-operator/lcm"
+	"github.com/google/go-cmp/cmp"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"reflect"
@@ -14,6 +16,7 @@ import (
 	minio "github.com/minio/minio-operator/pkg/apis/operator.min.io/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 func (m *MinIOReconciler) ProvisionInClusterSecretAsS3(minioInstamnce *minio.MinIOInstance) (*lcm.CRStatus, error) {
@@ -21,30 +24,44 @@ func (m *MinIOReconciler) ProvisionInClusterSecretAsS3(minioInstamnce *minio.Min
 	if err != nil {
 		return minioNotReadyStatus(GetMinIOSecretError, err.Error()), err
 	}
-	err = m.KubeClient.Create(inClusterSecret)
+	err = m.applySecret(inClusterSecret)
+	if err != nil {
+		return minioNotReadyStatus(CreateMinIOSecretError, err.Error()), err
+	}
+
+	properties := &lcm.Properties{}
+	properties.Add(s3Storage+ExternalStorageSecretSuffix, inClusterSecret.Name)
 
-	p := &lcm.Property{
-		Name:  s3Storage + ExternalStorageSecretSuffix,
-		Value: inClusterSecret.Name,
+	if m.isChartMuseumStoredWithRegistry() {
+		chartMuseumSecret, err := m.generateInClusterChartMuseumSecret(minioInstamnce)
+		if err != nil {
+			return minioNotReadyStatus(GetMinIOSecretError, err.Error()), err
+		}
+		err = m.applySecret(chartMuseumSecret)
+		if err != nil {
+			return minioNotReadyStatus(CreateMinIOSecretError, err.Error()), err
+		}
+		properties.Add(lcm.InClusterChartMuseumSecretForStorage, chartMuseumSecret.Name)
 	}
-	properties := &lcm.Properties{p}
-	return minioReadyStatus(properties), err
+
+	return minioReadyStatus(properties), nil
 }
 
 func (m *MinIOReconciler) generateInClusterSecret(minioInstamnce *minio.MinIOInstance) (*corev1.Secret, error) {
 	labels := m.getLabels()
 	labels[LabelOfStorageType] = inClusterStorage
-	accessKey, secretKey, err := m.getCredsFromSecret()
+	// harbor accesses its bucket with a scoped user instead of the minIO root credentials.
+	accessKey, secretKey, err := m.getUserCreds(minioConsumer{Name: registryMinIOConsumer, Bucket: DefaultBucket})
 	if err != nil {
 		return nil, err
 	}
 
 	data := map[string]string{
-		"accesskey":      string(accessKey),
-		"secretkey":      string(secretKey),
+		"accesskey":      accessKey,
+		"secretkey":      secretKey,
 		"region":         DefaultRegion,
 		"bucket":         DefaultBucket,
-		"regionendpoint": m.getServiceName() + "." + m.HarborCluster.Namespace,
+		"regionendpoint": m.getMinIOEndpointURL(),
 		"encrypt":        "false",
 		"secure":         "false",
 		"v4auth":         "false",
@@ -76,62 +93,162 @@ func (m *MinIOReconciler) generateInClusterSecret(minioInstamnce *minio.MinIOIns
 func (m *MinIOReconciler) ProvisionExternalStorage() (*lcm.CRStatus, error) {
 	exSecret, err := m.generateExternalSecret()
 	if err != nil {
-		return minioNotReadyStatus(err.Error(), err.Error()), err
+		return minioNotReadyStatus(GetExternalCredentialError, err.Error()), err
 	}
 
 	err = m.KubeClient.Create(exSecret)
 	if err != nil {
-		minioNotReadyStatus(CreateExternalSecretError, err.Error())
+		return minioNotReadyStatus(CreateExternalSecretError, err.Error()), err
 	}
 
-	p := &lcm.Property{
-		Name:  m.HarborCluster.Spec.Storage.Kind + ExternalStorageSecretSuffix,
-		Value: m.getExternalSecretName(),
+	err = m.applyExternalChartMuseumSecret()
+	if err != nil {
+		return minioNotReadyStatus(CreateExternalSecretError, err.Error()), err
 	}
-	properties := &lcm.Properties{p}
 
-	return minioReadyStatus(properties), nil
+	err = m.ensureExternalBucket()
+	if err != nil {
+		return minioNotReadyStatus(CreateExternalBucketError, err.Error()), err
+	}
+
+	return m.externalReadyStatus(), nil
+}
+
+// isExternalChartMuseumSecretNeeded check whether chartmuseum needs its own secret of the s3 compatible storage,
+// since chartmuseum can not read the storage secret of the registry.
+func (m *MinIOReconciler) isExternalChartMuseumSecretNeeded() bool {
+	kind := m.HarborCluster.Spec.Storage.Kind
+	return m.isChartMuseumStoredWithRegistry() && (kind == s3Storage || kind == ossStorage)
+}
+
+func (m *MinIOReconciler) applyExternalChartMuseumSecret() error {
+	if !m.isExternalChartMuseumSecretNeeded() {
+		return nil
+	}
+
+	secret, err := m.generateExternalChartMuseumSecret()
+	if err != nil {
+		return err
+	}
+	return m.applySecret(secret)
+}
+
+// generateExternalChartMuseumSecret returns the chartmuseum storage secret of the s3 or oss storage,
+// the charts are stored in the chartmuseum directory under the root directory of the registry.
+func (m *MinIOReconciler) generateExternalChartMuseumSecret() (*corev1.Secret, error) {
+	target, err := m.getS3Target()
+	if err != nil {
+		return nil, err
+	}
+
+	storage := m.HarborCluster.Spec.Storage
+	data := getChartMuseumS3Data(storage.Kind, target, target.getObjectKey(chartMuseumDirectory), storage.Kind == s3Storage && storage.S3.RegionEndpoint != "")
+
+	labels := m.getLabels()
+	labels[LabelOfStorageType] = m.HarborCluster.Spec.Storage.Kind
+
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        m.getExternalChartMuseumSecretName(),
+			Namespace:   m.HarborCluster.Namespace,
+			Labels:      labels,
+			Annotations: m.generateAnnotations(),
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(m.HarborCluster, goharborv1.HarborClusterGVK),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}, nil
+}
+
+// getChartMuseumS3Data returns the chartmuseum storage keys of the s3 or oss bucket, the charts are stored under prefix.
+// The endpoint of s3 is set only if it is not the default endpoint of the region.
+func getChartMuseumS3Data(kind string, target *s3Target, prefix string, withEndpoint bool) map[string][]byte {
+	switch kind {
+	case s3Storage:
+		data := map[string][]byte{
+			"kind":                  []byte(chartMuseumAmazonStorageKind),
+			"AMAZON_BUCKET":         []byte(target.Bucket),
+			"AMAZON_PREFIX":         []byte(prefix),
+			"AMAZON_REGION":         []byte(target.Region),
+			"AWS_ACCESS_KEY_ID":     []byte(target.AccessKey),
+			"AWS_SECRET_ACCESS_KEY": []byte(target.SecretKey),
+		}
+		if withEndpoint {
+			data["AMAZON_ENDPOINT"] = []byte(target.getURL())
+		}
+		return data
+	case ossStorage:
+		return map[string][]byte{
+			"kind":                            []byte(chartMuseumAlibabaStorageKind),
+			"ALIBABA_BUCKET":                  []byte(target.Bucket),
+			"ALIBABA_PREFIX":                  []byte(prefix),
+			"ALIBABA_ENDPOINT":                []byte(target.Endpoint),
+			"ALIBABA_CLOUD_ACCESS_KEY_ID":     []byte(target.AccessKey),
+			"ALIBABA_CLOUD_ACCESS_KEY_SECRET": []byte(target.SecretKey),
+		}
+	}
+	return nil
+}
+
+func (m *MinIOReconciler) getExternalChartMuseumSecretName() string {
+	return m.getExternalSecretName() + "-" + chartMuseumDirectory
 }
 
 func (m *MinIOReconciler) generateExternalSecret() (*corev1.Secret, error) {
 	var exSecret *corev1.Secret
 	labels := m.getLabels()
 
+	var err error
 	switch m.HarborCluster.Spec.Storage.Kind {
 	case azureStorage:
 		labels[LabelOfStorageType] = azureStorage
-		exSecret = m.generateAzureSecret(labels)
+		exSecret, err = m.generateAzureSecret(labels)
 	case gcsStorage:
 		labels[LabelOfStorageType] = gcsStorage
-		exSecret = m.generateGcsSecret(labels)
+		exSecret, err = m.generateGcsSecret(labels)
 	case s3Storage:
 		labels[LabelOfStorageType] = s3Storage
-		exSecret = m.generateS3Secret(labels)
+		exSecret, err = m.generateS3Secret(labels)
 	case swiftStorage:
 		labels[LabelOfStorageType] = swiftStorage
-		exSecret = m.generateSwiftSecret(labels)
+		exSecret, err = m.generateSwiftSecret(labels)
 	case ossStorage:
 		labels[LabelOfStorageType] = ossStorage
-		exSecret = m.generateOssSecret(labels)
+		exSecret, err = m.generateOssSecret(labels)
 	default:
 		return exSecret, fmt.Errorf(NotSupportType)
 	}
 
-	return exSecret, nil
+	return exSecret, err
 }
 
-func (m *MinIOReconciler) generateS3Secret(labels map[string]string) *corev1.Secret {
+func (m *MinIOReconciler) generateS3Secret(labels map[string]string) (*corev1.Secret, error) {
+	accessKey, err := m.getCredential(m.HarborCluster.Spec.Storage.S3.AccessKey, m.HarborCluster.Spec.Storage.S3.AccessKeyRef)
+	if err != nil {
+		return nil, err
+	}
+	secretKey, err := m.getCredential(m.HarborCluster.Spec.Storage.S3.SecretKey, m.HarborCluster.Spec.Storage.S3.SecretKeyRef)
+	if err != nil {
+		return nil, err
+	}
+
 	data := map[string]string{
 		"region":         m.HarborCluster.Spec.Storage.S3.Region,
 		"bucket":         m.HarborCluster.Spec.Storage.S3.Bucket,
-		"accesskey":      m.HarborCluster.Spec.Storage.S3.AccessKey,
-		"secretkey":      m.HarborCluster.Spec.Storage.S3.SecretKey,
+		"accesskey":      accessKey,
+		"secretkey":      secretKey,
 		"regionendpoint": m.HarborCluster.Spec.Storage.S3.RegionEndpoint,
 		"encrypt":        strconv.FormatBool(m.HarborCluster.Spec.Storage.S3.Encrypt),
 		"keyid":          m.HarborCluster.Spec.Storage.S3.KeyId,
 		"secure":         strconv.FormatBool(m.HarborCluster.Spec.Storage.S3.Secure),
 		"chunksize":      m.HarborCluster.Spec.Storage.S3.ChunkSize,
-		"rootdirectory":  m.HarborCluster.Spec.Storage.S3.RootDirectory,
+		"rootdirectory":  m.getExternalRootDirectory(m.HarborCluster.Spec.Storage.S3.RootDirectory),
 		"storageclass":   m.HarborCluster.Spec.Storage.S3.StorageClass,
 		"v4auth":         strconv.FormatBool(m.HarborCluster.Spec.Storage.S3.V4Auth),
 	}
@@ -154,14 +271,19 @@ func (m *MinIOReconciler) generateS3Secret(labels map[string]string) *corev1.Sec
 		Data: map[string][]byte{
 			s3Storage: dataJson,
 		},
-	}
+	}, nil
 }
 
-func (m *MinIOReconciler) generateAzureSecret(labels map[string]string) *corev1.Secret {
+func (m *MinIOReconciler) generateAzureSecret(labels map[string]string) (*corev1.Secret, error) {
+	accountKey, err := m.getCredential(m.HarborCluster.Spec.Storage.Azure.AccountKey, m.HarborCluster.Spec.Storage.Azure.AccountKeyRef)
+	if err != nil {
+		return nil, err
+	}
+
 	data := map[string]string{
 		"realm":       m.HarborCluster.Spec.Storage.Azure.Realm,
 		"accountname": m.HarborCluster.Spec.Storage.Azure.AccountName,
-		"accountkey":  m.HarborCluster.Spec.Storage.Azure.AccountKey,
+		"accountkey":  accountKey,
 		"container":   m.HarborCluster.Spec.Storage.Azure.Container,
 	}
 	dataJson, _ := json.Marshal(&data)
@@ -183,13 +305,18 @@ func (m *MinIOReconciler) generateAzureSecret(labels map[string]string) *corev1.
 		Data: map[string][]byte{
 			azureStorage: dataJson,
 		},
-	}
+	}, nil
 }
 
-func (m *MinIOReconciler) generateGcsSecret(labels map[string]string) *corev1.Secret {
+func (m *MinIOReconciler) generateGcsSecret(labels map[string]string) (*corev1.Secret, error) {
+	encodedKey, err := m.getCredential(m.HarborCluster.Spec.Storage.Gcs.EncodedKey, m.HarborCluster.Spec.Storage.Gcs.EncodedKeyRef)
+	if err != nil {
+		return nil, err
+	}
+
 	data := map[string]string{
 		"bucket":        m.HarborCluster.Spec.Storage.Gcs.Bucket,
-		"encodedkey":    m.HarborCluster.Spec.Storage.Gcs.EncodedKey,
+		"encodedkey":    encodedKey,
 		"rootdirectory": m.HarborCluster.Spec.Storage.Gcs.RootDirectory,
 		"chunksize":     m.HarborCluster.Spec.Storage.Gcs.ChunkSize,
 	}
@@ -212,14 +339,23 @@ func (m *MinIOReconciler) generateGcsSecret(labels map[string]string) *corev1.Se
 		Data: map[string][]byte{
 			gcsStorage: dataJson,
 		},
-	}
+	}, nil
 }
 
-func (m *MinIOReconciler) generateSwiftSecret(labels map[string]string) *corev1.Secret {
+func (m *MinIOReconciler) generateSwiftSecret(labels map[string]string) (*corev1.Secret, error) {
+	password, err := m.getCredential(m.HarborCluster.Spec.Storage.Swift.Password, m.HarborCluster.Spec.Storage.Swift.PasswordRef)
+	if err != nil {
+		return nil, err
+	}
+	secretKey, err := m.getCredential(m.HarborCluster.Spec.Storage.Swift.SecretKey, m.HarborCluster.Spec.Storage.Swift.SecretKeyRef)
+	if err != nil {
+		return nil, err
+	}
+
 	data := map[string]string{
 		"authurl":             m.HarborCluster.Spec.Storage.Swift.Authurl,
 		"username":            m.HarborCluster.Spec.Storage.Swift.Username,
-		"password":            m.HarborCluster.Spec.Storage.Swift.Password,
+		"password":            password,
 		"container":           m.HarborCluster.Spec.Storage.Swift.Container,
 		"region":              m.HarborCluster.Spec.Storage.Swift.Region,
 		"tenant":              m.HarborCluster.Spec.Storage.Swift.Tenant,
@@ -229,8 +365,8 @@ func (m *MinIOReconciler) generateSwiftSecret(labels map[string]string) *corev1.
 		"trustid":             m.HarborCluster.Spec.Storage.Swift.TrustId,
 		"insecureskipverify":  strconv.FormatBool(m.HarborCluster.Spec.Storage.Swift.InsecureSkipVerify),
 		"prefix":              m.HarborCluster.Spec.Storage.Swift.Prefix,
-		"secretkey":           m.HarborCluster.Spec.Storage.Swift.SecretKey,
-		"authversion":         string(m.HarborCluster.Spec.Storage.Swift.AuthVersion),
+		"secretkey":           secretKey,
+		"authversion":         strconv.Itoa(m.HarborCluster.Spec.Storage.Swift.AuthVersion),
 		"endpointtype":        m.HarborCluster.Spec.Storage.Swift.EndpointType,
 		"tempurlcontainerkey": strconv.FormatBool(m.HarborCluster.Spec.Storage.Swift.TempurlContainerkey),
 		"tempurlmethods":      m.HarborCluster.Spec.Storage.Swift.TempurlMethods,
@@ -255,13 +391,18 @@ func (m *MinIOReconciler) generateSwiftSecret(labels map[string]string) *corev1.
 		Data: map[string][]byte{
 			swiftStorage: dataJson,
 		},
-	}
+	}, nil
 }
 
-func (m *MinIOReconciler) generateOssSecret(labels map[string]string) *corev1.Secret {
+func (m *MinIOReconciler) generateOssSecret(labels map[string]string) (*corev1.Secret, error) {
+	accessKeySecret, err := m.getCredential(m.HarborCluster.Spec.Storage.Oss.AccessKeySecret, m.HarborCluster.Spec.Storage.Oss.AccessKeySecretRef)
+	if err != nil {
+		return nil, err
+	}
+
 	data := map[string]string{
 		"accesskeyid":     m.HarborCluster.Spec.Storage.Oss.AccessKeyId,
-		"accesskeysecret": m.HarborCluster.Spec.Storage.Oss.AccessKeySecret,
+		"accesskeysecret": accessKeySecret,
 		"region":          m.HarborCluster.Spec.Storage.Oss.Region,
 		"bucket":          m.HarborCluster.Spec.Storage.Oss.Bucket,
 		"endpoint":        m.HarborCluster.Spec.Storage.Oss.Region,
@@ -269,7 +410,7 @@ func (m *MinIOReconciler) generateOssSecret(labels map[string]string) *corev1.Se
 		"encrypt":         m.HarborCluster.Spec.Storage.Oss.Encrypt,
 		"secure":          m.HarborCluster.Spec.Storage.Oss.Secure,
 		"chunksize":       m.HarborCluster.Spec.Storage.Oss.ChunkSize,
-		"rootdirectory":   m.HarborCluster.Spec.Storage.Oss.RootDirectory,
+		"rootdirectory":   m.getExternalRootDirectory(m.HarborCluster.Spec.Storage.Oss.RootDirectory),
 	}
 	dataJson, _ := json.Marshal(&data)
 	return &corev1.Secret{
@@ -290,7 +431,7 @@ func (m *MinIOReconciler) generateOssSecret(labels map[string]string) *corev1.Se
 		Data: map[string][]byte{
 			ossStorage: dataJson,
 		},
-	}
+	}, nil
 }
 
 func (m *MinIOReconciler) Provision() (*lcm.CRStatus, error) {
@@ -358,15 +499,10 @@ func (m *MinIOReconciler) generateMinIOCR() *minio.MinIOInstance {
 				Labels:      m.getLabels(),
 				Annotations: m.generateAnnotations(),
 			},
-			ServiceName: m.getServiceName(),
-			Image:       "minio/minio:" + m.HarborCluster.Spec.Storage.InCluster.Spec.Version,
-			Zones: []minio.Zone{
-				{
-					Name:    m.HarborCluster.Name + "-" + DefaultZone,
-					Servers: m.HarborCluster.Spec.Storage.InCluster.Spec.Replicas,
-				},
-			},
-			VolumesPerServer:    1,
+			ServiceName:         m.getServiceName(),
+			Image:               "minio/minio:" + m.HarborCluster.Spec.Storage.InCluster.Spec.Version,
+			Zones:               m.getZones(),
+			VolumesPerServer:    m.getVolumesPerServer(),
 			Mountpath:           minio.MinIOVolumeMountPath,
 			VolumeClaimTemplate: m.getVolumeClaimTemplate(),
 			CredsSecret: &corev1.LocalObjectReference{
@@ -379,12 +515,7 @@ func (m *MinIOReconciler) generateMinIOCR() *minio.MinIOInstance {
 				OrganizationName: []string{},
 				DNSNames:         []string{},
 			},
-			Env: []corev1.EnvVar{
-				corev1.EnvVar{
-					Name:  "MINIO_BROWSER",
-					Value: "on",
-				},
-			},
+			Env:       m.getMinIOEnv(),
 			Resources: *m.getResourceRequirements(), //m.HarborCluster.Spec.Storage.InCluster.Spec.Resources,
 			Liveness: &minio.Liveness{
 				InitialDelaySeconds: 120,
@@ -398,8 +529,12 @@ func (m *MinIOReconciler) generateMinIOCR() *minio.MinIOInstance {
 	}
 }
 
+// getServiceName returns the name of the minIO instance serving harbor, which is also the name of its service.
 func (m *MinIOReconciler) getServiceName() string {
-	return m.HarborCluster.Name + "-" + DefaultMinIO
+	if m.instanceName != "" {
+		return m.instanceName
+	}
+	return m.getDefaultInstanceName()
 }
 
 func (m *MinIOReconciler) getResourceRequirements() *corev1.ResourceRequirements {
@@ -421,10 +556,37 @@ func (m *MinIOReconciler) getResourceRequirements() *corev1.ResourceRequirements
 	}
 }
 
+// getZones returns a minIO zone per pool, the name of the first zone is kept for the minIO created before pools.
+func (m *MinIOReconciler) getZones() []minio.Zone {
+	pools := m.HarborCluster.Spec.Storage.InCluster.Spec.GetPools()
+	zones := make([]minio.Zone, 0, len(pools))
+	for i, pool := range pools {
+		name := m.HarborCluster.Name + "-" + DefaultZone
+		if i > 0 {
+			name = fmt.Sprintf("%s-%d", name, i)
+		}
+		zones = append(zones, minio.Zone{
+			Name:    name,
+			Servers: pool.Servers,
+		})
+	}
+	return zones
+}
+
+// getVolumesPerServer returns the volumes per server of the first pool, which are the same for all pools.
+func (m *MinIOReconciler) getVolumesPerServer() int {
+	volumesPerServer := m.HarborCluster.Spec.Storage.InCluster.Spec.GetPools()[0].VolumesPerServer
+	if volumesPerServer == 0 {
+		return minio.DefaultVolumesPerServer
+	}
+	return volumesPerServer
+}
+
 func (m *MinIOReconciler) getVolumeClaimTemplate() *corev1.PersistentVolumeClaim {
-	isEmpty := reflect.DeepEqual(m.HarborCluster.Spec.Storage.InCluster.Spec.VolumeClaimTemplate, corev1.PersistentVolumeClaim{})
+	volumeClaimTemplate := m.HarborCluster.Spec.Storage.InCluster.Spec.GetPools()[0].VolumeClaimTemplate
+	isEmpty := reflect.DeepEqual(volumeClaimTemplate, corev1.PersistentVolumeClaim{})
 	if !isEmpty {
-		return &m.HarborCluster.Spec.Storage.InCluster.Spec.VolumeClaimTemplate
+		return &volumeClaimTemplate
 	}
 	defaultStorageClass := "default"
 	return &corev1.PersistentVolumeClaim{
@@ -463,7 +625,7 @@ func (m *MinIOReconciler) generateService() *corev1.Service {
 		},
 		Spec: corev1.ServiceSpec{
 			Type:     corev1.ServiceTypeClusterIP,
-			Selector: m.getLabels(),
+			Selector: m.getServiceSelector(),
 			Ports: []corev1.ServicePort{
 				{
 					Port:       9000,
@@ -503,3 +665,22 @@ func (m *MinIOReconciler) getCredsFromSecret() ([]byte, []byte, error) {
 	err := m.KubeClient.Get(namespaced, &minIOSecret)
 	return minIOSecret.Data["accesskey"], minIOSecret.Data["secretkey"], err
 }
+
+// applySecret creates the secret, or updates its data if it differs from the current one.
+func (m *MinIOReconciler) applySecret(secret *corev1.Secret) error {
+	var current corev1.Secret
+	err := m.KubeClient.Get(types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}, &current)
+	if k8serror.IsNotFound(err) {
+		return m.KubeClient.Create(secret)
+	} else if err != nil {
+		return err
+	}
+
+	if cmp.Equal(secret.Data, current.Data) {
+		return nil
+	}
+
+	current.Labels = secret.Labels
+	current.Data = secret.Data
+	return m.KubeClient.Update(&current)
+}
@@ -0,0 +1,294 @@
+package storage
+
+import (
+	"context"
+	"path"
+	"time"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	minv6 "github.com/minio/minio-go/v6"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	DefaultReplicationInterval = 5 * time.Minute
+
+	// DefaultReplicationBatchDuration is how long objects are replicated in a reconciliation,
+	// the replication pass is resumed in the next one.
+	DefaultReplicationBatchDuration = 10 * time.Second
+
+	// replicationBatchInterval is the delay before the next batch of the replication pass in progress.
+	replicationBatchInterval = 5 * time.Second
+	// replicationRetryInterval is the delay before replicating again after a failure.
+	replicationRetryInterval = time.Minute
+)
+
+func getReplication(harborCluster *goharborv1.HarborCluster) *goharborv1.MinIOReplication {
+	storage := harborCluster.Spec.Storage
+	if storage == nil || storage.Kind != inClusterStorage || storage.InCluster == nil {
+		return nil
+	}
+	return storage.InCluster.Replication
+}
+
+func getReplicationInterval(replication *goharborv1.MinIOReplication) time.Duration {
+	if replication.Interval == nil {
+		return DefaultReplicationInterval
+	}
+	return replication.Interval.Duration
+}
+
+// GetReplicationRequeue returns the delay until the next replication batch of the in-cluster minIO,
+// it returns false if the replication is not configured.
+func GetReplicationRequeue(harborCluster *goharborv1.HarborCluster, now time.Time) (time.Duration, bool) {
+	replication := getReplication(harborCluster)
+	if replication == nil {
+		return 0, false
+	}
+
+	status := harborCluster.Status.StorageReplication
+	switch {
+	case status == nil:
+		return replicationBatchInterval, true
+	case status.Message != "":
+		return replicationRetryInterval, true
+	case status.CurrentSyncStartTime != nil || status.LastSyncStartTime == nil:
+		return replicationBatchInterval, true
+	}
+
+	requeueAfter := status.LastSyncStartTime.Add(getReplicationInterval(replication)).Sub(now)
+	if requeueAfter < replicationBatchInterval {
+		requeueAfter = replicationBatchInterval
+	}
+	return requeueAfter, true
+}
+
+func isReplicationDue(replication *goharborv1.MinIOReplication, status *goharborv1.StorageReplicationStatus, now time.Time) bool {
+	if status.CurrentSyncStartTime != nil || status.LastSyncStartTime == nil {
+		return true
+	}
+	return !now.Before(status.LastSyncStartTime.Add(getReplicationInterval(replication)))
+}
+
+// reconcileReplication replicates a batch of the objects of the ready minIO to the remote storage.
+// A replication pass checks every object of the buckets of harbor in lexicographical order, and copies the objects
+// which are missing in the remote bucket, differ in size or are modified after their replica. The objects deleted
+// from minIO are kept in the remote bucket. A failure is kept in the status and retried, it does not affect the
+// readiness of the storage.
+func (m *MinIOReconciler) reconcileReplication() {
+	replication := getReplication(m.HarborCluster)
+	if replication == nil {
+		m.HarborCluster.Status.StorageReplication = nil
+		return
+	}
+
+	status := m.HarborCluster.Status.StorageReplication
+	if status == nil {
+		status = &goharborv1.StorageReplicationStatus{}
+		m.HarborCluster.Status.StorageReplication = status
+	}
+
+	now := time.Now()
+	if status.LastSyncStartTime != nil {
+		status.Lag = &metav1.Duration{Duration: now.Sub(status.LastSyncStartTime.Time).Round(time.Second)}
+	}
+	if !isReplicationDue(replication, status, now) {
+		return
+	}
+
+	if status.CurrentSyncStartTime == nil {
+		start := metav1.NewTime(now)
+		status.CurrentSyncStartTime = &start
+		status.Buckets = nil
+	}
+
+	err := m.replicate(status)
+	if err != nil {
+		m.Log.Error(err, "Storage replication failed, it will be retried")
+		status.Message = err.Error()
+		return
+	}
+	status.Message = ""
+}
+
+func (m *MinIOReconciler) replicate(status *goharborv1.StorageReplicationStatus) error {
+	source, err := m.getInstanceTarget()
+	if err != nil {
+		return err
+	}
+	target, err := m.getReplicationTarget()
+	if err != nil {
+		return err
+	}
+
+	if status.Buckets == nil {
+		status.Buckets, err = getReplicationBuckets(source, target)
+		if err != nil {
+			return err
+		}
+	}
+
+	done, err := m.replicateObjects(source, target, status.Buckets, time.Now().Add(DefaultReplicationBatchDuration))
+	if err != nil || !done {
+		return err
+	}
+
+	now := metav1.Now()
+	status.LastSyncTime = &now
+	status.LastSyncStartTime = status.CurrentSyncStartTime
+	status.CurrentSyncStartTime = nil
+	status.Lag = &metav1.Duration{Duration: now.Sub(status.LastSyncStartTime.Time).Round(time.Second)}
+	m.Log.Info("Storage replication pass completed", "bucket", target.Bucket, "lag", status.Lag.Duration.String())
+	return nil
+}
+
+// getReplicationTarget returns the bucket of the remote storage, and the credentials to access it.
+// The root directory defaults to a directory of the harbor cluster, so that several harbor clusters can share the bucket.
+func (m *MinIOReconciler) getReplicationTarget() (*s3Target, error) {
+	replication := getReplication(m.HarborCluster)
+	accessKey, err := m.getCredential("", replication.AccessKeyRef)
+	if err != nil {
+		return nil, err
+	}
+	secretKey, err := m.getCredential("", replication.SecretKeyRef)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, secure, err := parseEndpoint(replication.Endpoint, true)
+	if err != nil {
+		return nil, err
+	}
+
+	rootDirectory := replication.RootDirectory
+	if rootDirectory == "" {
+		rootDirectory = path.Join("/", m.HarborCluster.Namespace, m.HarborCluster.Name)
+	}
+
+	return &s3Target{
+		Endpoint:      endpoint,
+		AccessKey:     accessKey,
+		SecretKey:     secretKey,
+		Secure:        secure,
+		Region:        replication.Region,
+		Bucket:        replication.Bucket,
+		RootDirectory: rootDirectory,
+	}, nil
+}
+
+// getReplicationBuckets returns the buckets to replicate. They are laid out in the remote bucket as an external s3
+// storage of harbor: the registry bucket under the root directory, and the chartmuseum bucket in the chartmuseum
+// directory under it.
+func getReplicationBuckets(source, target *s3Target) ([]goharborv1.BucketReplicationStatus, error) {
+	client, err := source.newClient()
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := []goharborv1.BucketReplicationStatus{
+		{Bucket: DefaultBucket, Prefix: target.getObjectKey("")},
+	}
+
+	exists, err := client.BucketExists(DefaultChartMuseumBucket)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		buckets = append(buckets, goharborv1.BucketReplicationStatus{
+			Bucket: DefaultChartMuseumBucket,
+			Prefix: target.getObjectKey(chartMuseumDirectory),
+		})
+	}
+
+	return buckets, nil
+}
+
+// replicateObjects checks the objects of the buckets until all are checked or the deadline is reached.
+// The replicas are listed a page at a time along with the objects, instead of being requested one by one.
+func (m *MinIOReconciler) replicateObjects(source, target *s3Target, buckets []goharborv1.BucketReplicationStatus, deadline time.Time) (bool, error) {
+	sourceClient, err := source.newClient()
+	if err != nil {
+		return false, err
+	}
+	targetClient, err := target.newClient()
+	if err != nil {
+		return false, err
+	}
+
+	for i := range buckets {
+		bucket := &buckets[i]
+		for !bucket.Done {
+			result, err := source.listObjectsAfter(m.Ctx, bucket.Bucket, "", bucket.LastKey)
+			if err != nil {
+				return false, err
+			}
+
+			var replicas map[string]minv6.ObjectInfo
+			if len(result.Contents) > 0 {
+				lastKey := result.Contents[len(result.Contents)-1].Key
+				replicas, err = target.listReplicas(m.Ctx, bucket, lastKey)
+				if err != nil {
+					return false, err
+				}
+			}
+
+			for _, object := range result.Contents {
+				if time.Now().After(deadline) {
+					return false, nil
+				}
+
+				targetKey := joinObjectKey(bucket.Prefix, object.Key)
+				replica, ok := replicas[targetKey]
+				if !ok || replica.Size != object.Size || replica.LastModified.Before(object.LastModified) {
+					err := copyObject(m.Ctx, sourceClient, targetClient, bucket.Bucket, target.Bucket, targetKey, object)
+					if err != nil {
+						return false, err
+					}
+					bucket.ReplicatedObjects++
+					bucket.ReplicatedBytes += object.Size
+				}
+				bucket.LastKey = object.Key
+			}
+
+			if !result.IsTruncated {
+				bucket.Done = true
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// listReplicas returns the objects of the remote bucket replicating the objects of the bucket after its last
+// checked object, up to the object lastKey.
+func (t *s3Target) listReplicas(ctx context.Context, bucket *goharborv1.BucketReplicationStatus, lastKey string) (map[string]minv6.ObjectInfo, error) {
+	prefix := bucket.Prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+	startAfter := ""
+	if bucket.LastKey != "" {
+		startAfter = joinObjectKey(bucket.Prefix, bucket.LastKey)
+	}
+	last := joinObjectKey(bucket.Prefix, lastKey)
+
+	replicas := make(map[string]minv6.ObjectInfo)
+	for {
+		result, err := t.listObjectsAfter(ctx, t.Bucket, prefix, startAfter)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			if object.Key > last {
+				return replicas, nil
+			}
+			replicas[object.Key] = object
+			startAfter = object.Key
+		}
+
+		if !result.IsTruncated || len(result.Contents) == 0 {
+			return replicas, nil
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newReplicationCluster(replication *goharborv1.MinIOReplication, status *goharborv1.StorageReplicationStatus) *goharborv1.HarborCluster {
+	return &goharborv1.HarborCluster{
+		Spec: goharborv1.HarborClusterSpec{
+			Storage: &goharborv1.Storage{
+				Kind:      inClusterStorage,
+				InCluster: &goharborv1.InCluster{Replication: replication},
+			},
+		},
+		Status: goharborv1.HarborClusterStatus{StorageReplication: status},
+	}
+}
+
+func TestGetReplicationRequeue(t *testing.T) {
+	now := time.Now()
+	lastStart := metav1.NewTime(now.Add(-2 * time.Minute))
+	longAgo := metav1.NewTime(now.Add(-time.Hour))
+	replication := &goharborv1.MinIOReplication{Bucket: "backup"}
+
+	tests := []struct {
+		name          string
+		harborCluster *goharborv1.HarborCluster
+		want          time.Duration
+		wantOK        bool
+	}{
+		{
+			name:          "replication not configured",
+			harborCluster: newReplicationCluster(nil, nil),
+			wantOK:        false,
+		},
+		{
+			name:          "replication not started",
+			harborCluster: newReplicationCluster(replication, nil),
+			want:          replicationBatchInterval,
+			wantOK:        true,
+		},
+		{
+			name:          "pass in progress",
+			harborCluster: newReplicationCluster(replication, &goharborv1.StorageReplicationStatus{CurrentSyncStartTime: &lastStart}),
+			want:          replicationBatchInterval,
+			wantOK:        true,
+		},
+		{
+			name:          "failed pass",
+			harborCluster: newReplicationCluster(replication, &goharborv1.StorageReplicationStatus{CurrentSyncStartTime: &lastStart, Message: "access denied"}),
+			want:          replicationRetryInterval,
+			wantOK:        true,
+		},
+		{
+			name:          "next pass after the interval",
+			harborCluster: newReplicationCluster(replication, &goharborv1.StorageReplicationStatus{LastSyncStartTime: &lastStart}),
+			want:          DefaultReplicationInterval - 2*time.Minute,
+			wantOK:        true,
+		},
+		{
+			name:          "next pass overdue",
+			harborCluster: newReplicationCluster(replication, &goharborv1.StorageReplicationStatus{LastSyncStartTime: &longAgo}),
+			want:          replicationBatchInterval,
+			wantOK:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := GetReplicationRequeue(tt.harborCluster, now)
+			if ok != tt.wantOK {
+				t.Fatalf("GetReplicationRequeue() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("GetReplicationRequeue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsReplicationDue(t *testing.T) {
+	now := time.Now()
+	lastStart := metav1.NewTime(now.Add(-2 * time.Minute))
+	interval := &metav1.Duration{Duration: time.Minute}
+
+	tests := []struct {
+		name        string
+		replication *goharborv1.MinIOReplication
+		status      *goharborv1.StorageReplicationStatus
+		want        bool
+	}{
+		{
+			name:        "never replicated",
+			replication: &goharborv1.MinIOReplication{},
+			status:      &goharborv1.StorageReplicationStatus{},
+			want:        true,
+		},
+		{
+			name:        "pass in progress",
+			replication: &goharborv1.MinIOReplication{},
+			status:      &goharborv1.StorageReplicationStatus{LastSyncStartTime: &lastStart, CurrentSyncStartTime: &lastStart},
+			want:        true,
+		},
+		{
+			name:        "within the default interval",
+			replication: &goharborv1.MinIOReplication{},
+			status:      &goharborv1.StorageReplicationStatus{LastSyncStartTime: &lastStart},
+			want:        false,
+		},
+		{
+			name:        "after the configured interval",
+			replication: &goharborv1.MinIOReplication{Interval: interval},
+			status:      &goharborv1.StorageReplicationStatus{LastSyncStartTime: &lastStart},
+			want:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isReplicationDue(tt.replication, tt.status, now); got != tt.want {
+				t.Errorf("isReplicationDue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetReplicationBuckets(t *testing.T) {
+	tests := []struct {
+		name    string
+		buckets []string
+		want    []goharborv1.BucketReplicationStatus
+	}{
+		{
+			name:    "registry bucket only",
+			buckets: []string{DefaultBucket},
+			want: []goharborv1.BucketReplicationStatus{
+				{Bucket: DefaultBucket, Prefix: "harbor"},
+			},
+		},
+		{
+			name:    "registry and chartmuseum buckets",
+			buckets: []string{DefaultBucket, DefaultChartMuseumBucket},
+			want: []goharborv1.BucketReplicationStatus{
+				{Bucket: DefaultBucket, Prefix: "harbor"},
+				{Bucket: DefaultChartMuseumBucket, Prefix: "harbor/chartmuseum"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newFakeS3Server(tt.buckets...)
+			defer source.Close()
+			remote := newFakeS3Server("backup")
+			defer remote.Close()
+
+			got, err := getReplicationBuckets(source.getTarget(""), remote.getTarget("backup"))
+			if err != nil {
+				t.Fatalf("getReplicationBuckets() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("getReplicationBuckets() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("getReplicationBuckets()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReplicateObjects(t *testing.T) {
+	modified := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	source := newFakeS3Server(DefaultBucket, DefaultChartMuseumBucket)
+	defer source.Close()
+	source.putObject(DefaultBucket, "blobs/a", "aaa", modified)
+	source.putObject(DefaultBucket, "blobs/b", "bbbb", modified)
+	source.putObject(DefaultBucket, "blobs/c", "cc", modified)
+	source.putObject(DefaultBucket, "blobs/d", "dd", modified)
+	source.putObject(DefaultChartMuseumBucket, "index.yaml", "charts", modified)
+
+	remote := newFakeS3Server("backup")
+	defer remote.Close()
+	// replicated already
+	remote.putObject("backup", "harbor/blobs/a", "aaa", modified.Add(time.Minute))
+	// differs in size
+	remote.putObject("backup", "harbor/blobs/b", "bb", modified.Add(time.Minute))
+	// modified after its replica
+	remote.putObject("backup", "harbor/blobs/c", "xx", modified.Add(-time.Minute))
+	// deleted from minIO
+	remote.putObject("backup", "harbor/blobs/0", "deleted", modified)
+
+	// list a few objects at a time, so that the replication goes through several pages.
+	source.maxKeys = 2
+	remote.maxKeys = 2
+
+	m := &MinIOReconciler{Ctx: context.Background()}
+	sourceTarget, target := source.getTarget(""), remote.getTarget("backup")
+	buckets, err := getReplicationBuckets(sourceTarget, target)
+	if err != nil {
+		t.Fatalf("getReplicationBuckets() error = %v", err)
+	}
+
+	done, err := m.replicateObjects(sourceTarget, target, buckets, time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatalf("replicateObjects() error = %v", err)
+	}
+	if done || buckets[0].Done || buckets[0].ReplicatedObjects != 0 {
+		t.Fatalf("replicateObjects() after the deadline = %v, buckets %v, want nothing replicated", done, buckets)
+	}
+
+	done, err = m.replicateObjects(sourceTarget, target, buckets, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("replicateObjects() error = %v", err)
+	}
+	if !done {
+		t.Fatalf("replicateObjects() = %v, want true", done)
+	}
+
+	want := []goharborv1.BucketReplicationStatus{
+		{Bucket: DefaultBucket, Prefix: "harbor", ReplicatedObjects: 3, ReplicatedBytes: 8, LastKey: "blobs/d", Done: true},
+		{Bucket: DefaultChartMuseumBucket, Prefix: "harbor/chartmuseum", ReplicatedObjects: 1, ReplicatedBytes: 6, LastKey: "index.yaml", Done: true},
+	}
+	for i := range want {
+		if buckets[i] != want[i] {
+			t.Errorf("replicateObjects() bucket = %v, want %v", buckets[i], want[i])
+		}
+	}
+
+	replicas := map[string]string{
+		"harbor/blobs/0":                "deleted",
+		"harbor/blobs/a":                "aaa",
+		"harbor/blobs/b":                "bbbb",
+		"harbor/blobs/c":                "cc",
+		"harbor/blobs/d":                "dd",
+		"harbor/chartmuseum/index.yaml": "charts",
+	}
+	for key, content := range replicas {
+		if got, ok := remote.getObject("backup", key); !ok || got != content {
+			t.Errorf("replica %s = %q, want %q", key, got, content)
+		}
+	}
+
+	// the next pass copies nothing, since every object is replicated.
+	buckets, err = getReplicationBuckets(sourceTarget, target)
+	if err != nil {
+		t.Fatalf("getReplicationBuckets() error = %v", err)
+	}
+	done, err = m.replicateObjects(sourceTarget, target, buckets, time.Now().Add(time.Minute))
+	if err != nil || !done {
+		t.Fatalf("replicateObjects() = %v, error = %v", done, err)
+	}
+	for _, bucket := range buckets {
+		if bucket.ReplicatedObjects != 0 {
+			t.Errorf("replicateObjects() replicated %d objects of %s again", bucket.ReplicatedObjects, bucket.Bucket)
+		}
+	}
+}
+
+func TestListReplicas(t *testing.T) {
+	modified := time.Now().Truncate(time.Second)
+
+	remote := newFakeS3Server("backup")
+	defer remote.Close()
+	remote.maxKeys = 2
+	for _, key := range []string{"harbor/a", "harbor/b", "harbor/c", "harbor/d", "harbor/e", "harbor-other/a", "other/a"} {
+		remote.putObject("backup", key, "replica", modified)
+	}
+
+	tests := []struct {
+		name    string
+		lastKey string
+		after   string
+		want    []string
+	}{
+		{
+			name:  "first page",
+			after: "d",
+			want:  []string{"harbor/a", "harbor/b", "harbor/c", "harbor/d"},
+		},
+		{
+			name:    "resumed page",
+			lastKey: "b",
+			after:   "d",
+			want:    []string{"harbor/c", "harbor/d"},
+		},
+		{
+			name:    "last page",
+			lastKey: "d",
+			after:   "z",
+			want:    []string{"harbor/e"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := &goharborv1.BucketReplicationStatus{Bucket: DefaultBucket, Prefix: "harbor", LastKey: tt.lastKey}
+			got, err := remote.getTarget("backup").listReplicas(context.Background(), bucket, tt.after)
+			if err != nil {
+				t.Fatalf("listReplicas() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("listReplicas() = %v, want %v", got, tt.want)
+			}
+			for _, key := range tt.want {
+				if _, ok := got[key]; !ok {
+					t.Errorf("listReplicas() misses %s", key)
+				}
+			}
+		})
+	}
+}
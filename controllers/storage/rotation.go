@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goharbor/harbor-cluster-operator/controllers/common"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// the keys of the creds secret which keep the replaced root credentials until minIO is restarted
+	oldAccessKey = "oldaccesskey"
+	oldSecretKey = "oldsecretkey"
+	// the key of the user creds secret which keeps the replaced users until harbor is restarted
+	retiredAccessKeys = "retiredaccesskeys"
+)
+
+// RotateCredentials rotates the credentials of the minIO users of harbor components, and the minIO root credentials.
+// A new user is created for every component, the replaced user keeps working until the harbor pods are restarted
+// with the new one, then it is removed by RemoveRetiredCredentials.
+// The user is updated by ensureMinIOUsers too, which makes an interrupted rotation converge.
+// It must be called after Reconcile reports the storage ready.
+func (m *MinIOReconciler) RotateCredentials() error {
+	if m.HarborCluster.Spec.Storage.Kind != inClusterStorage || m.CurrentMinIOCR == nil {
+		return nil
+	}
+
+	err := m.minioInit()
+	if err != nil {
+		return err
+	}
+
+	for _, consumer := range m.getMinIOConsumers() {
+		err := m.rotateUserCreds(consumer)
+		if err != nil {
+			return fmt.Errorf("rotate minIO user of %s: %w", consumer.Name, err)
+		}
+	}
+
+	err = m.rotateRootCreds()
+	if err != nil {
+		return fmt.Errorf("rotate minIO root credentials: %w", err)
+	}
+
+	m.Log.Info("MinIO credentials rotated", "namespace", m.HarborCluster.Namespace, "name", m.HarborCluster.Name)
+	return nil
+}
+
+// rotateUserCreds replaces the user of the consumer by a new one, the replaced user is recorded as retired.
+// The secret is updated first, so that ensureMinIOUsers creates the new user if adding it fails.
+func (m *MinIOReconciler) rotateUserCreds(consumer minioConsumer) error {
+	var secret corev1.Secret
+	err := m.KubeClient.Get(m.getUserCredsNamespacedName(consumer), &secret)
+	if err != nil {
+		return err
+	}
+
+	retired := append(getRetiredAccessKeys(&secret), string(secret.Data["accesskey"]))
+	accessKey := consumer.Name + "-" + common.RandomString(minioUserAccessKeyPrefixLength, "a")
+	secretKey := common.RandomString(minioUserSecretKeyLength, "a")
+	secret.Data["accesskey"] = []byte(accessKey)
+	secret.Data["secretkey"] = []byte(secretKey)
+	secret.Data[retiredAccessKeys] = []byte(strings.Join(retired, ","))
+	err = m.KubeClient.Update(&secret)
+	if err != nil {
+		return err
+	}
+
+	err = m.MinioClient.AddUser(accessKey, secretKey)
+	if err != nil {
+		return err
+	}
+	return m.MinioClient.SetUserPolicy(m.getUserPolicyName(consumer), accessKey)
+}
+
+// rotateRootCreds replaces the root credentials of a standalone minIO, the replaced ones are loaded as the old
+// credentials of minIO to decrypt its config when the server is restarted.
+// The root credentials of a distributed minIO are not rotated: its servers authenticate each other with them,
+// so a server restarted with new ones could not join the others, and restarting every server at once would stop
+// the storage of harbor. The users of harbor components are rotated anyway.
+// The root credentials are not rotated again until minIO is restarted with the previous rotation, otherwise the
+// config of minIO could be encrypted with credentials which are not kept anymore.
+func (m *MinIOReconciler) rotateRootCreds() error {
+	if !isStandaloneInstance(m.CurrentMinIOCR) {
+		m.Log.Info("Skip rotating the root credentials of the distributed minIO", "namespace", m.HarborCluster.Namespace, "name", m.CurrentMinIOCR.Name)
+		return nil
+	}
+
+	var secret corev1.Secret
+	err := m.KubeClient.Get(m.getMinIOSecretNamespacedName(), &secret)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := secret.Data[oldAccessKey]; ok {
+		m.Log.Info("Skip rotating minIO root credentials, the previous rotation is not finished", "namespace", secret.Namespace, "name", secret.Name)
+		return nil
+	}
+
+	secret.Data[oldAccessKey] = secret.Data["accesskey"]
+	secret.Data[oldSecretKey] = secret.Data["secretkey"]
+	secret.Data["accesskey"] = []byte(common.RandomString(8, "a"))
+	secret.Data["secretkey"] = []byte(common.RandomString(8, "a"))
+	err = m.KubeClient.Update(&secret)
+	if err != nil {
+		return err
+	}
+
+	return m.restartMinIOPod()
+}
+
+// restartMinIOPod deletes the pod of the standalone minIO, so that it is recreated with the rotated credentials.
+// The old root credentials are kept until the new pod is ready, see removeOldRootCreds.
+func (m *MinIOReconciler) restartMinIOPod() error {
+	var pod corev1.Pod
+	err := m.KubeClient.Get(types.NamespacedName{Namespace: m.HarborCluster.Namespace, Name: m.getServiceName() + "-0"}, &pod)
+	if k8serror.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if pod.DeletionTimestamp != nil {
+		return nil
+	}
+	return m.KubeClient.Delete(&pod)
+}
+
+// RemoveRetiredCredentials removes the minIO users replaced by the rotations once harbor is restarted,
+// and the old root credentials once every minIO server is restarted with the new ones.
+// It must be called after Reconcile reports the storage ready.
+func (m *MinIOReconciler) RemoveRetiredCredentials() error {
+	if m.HarborCluster.Spec.Storage.Kind != inClusterStorage || m.CurrentMinIOCR == nil {
+		return nil
+	}
+
+	err := m.minioInit()
+	if err != nil {
+		return err
+	}
+
+	for _, consumer := range m.getMinIOConsumers() {
+		var secret corev1.Secret
+		err := m.KubeClient.Get(m.getUserCredsNamespacedName(consumer), &secret)
+		if err != nil {
+			return err
+		}
+
+		retired := getRetiredAccessKeys(&secret)
+		if len(retired) == 0 {
+			continue
+		}
+		for _, accessKey := range retired {
+			err := m.MinioClient.RemoveUser(accessKey)
+			if err != nil {
+				return fmt.Errorf("remove retired minIO user of %s: %w", consumer.Name, err)
+			}
+		}
+
+		delete(secret.Data, retiredAccessKeys)
+		err = m.KubeClient.Update(&secret)
+		if err != nil {
+			return err
+		}
+		m.Log.Info("Retired minIO users removed", "consumer", consumer.Name, "count", len(retired))
+	}
+
+	return m.removeOldRootCreds()
+}
+
+// removeOldRootCreds removes the old root credentials from the creds secret, once every server of minIO is ready.
+// minIO re-encrypts its config with the new credentials when the first server starts.
+func (m *MinIOReconciler) removeOldRootCreds() error {
+	var secret corev1.Secret
+	err := m.KubeClient.Get(m.getMinIOSecretNamespacedName(), &secret)
+	if err != nil {
+		return err
+	}
+	if _, ok := secret.Data[oldAccessKey]; !ok {
+		return nil
+	}
+
+	isReady, _, _, err := m.checkMinIOReady()
+	if err != nil || !isReady {
+		return err
+	}
+
+	delete(secret.Data, oldAccessKey)
+	delete(secret.Data, oldSecretKey)
+	return m.KubeClient.Update(&secret)
+}
+
+func getRetiredAccessKeys(secret *corev1.Secret) []string {
+	value := string(secret.Data[retiredAccessKeys])
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+func (m *MinIOReconciler) getOptionalCredsEnv(name, key string) corev1.EnvVar {
+	optional := true
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: m.getMinIOSecretNamespacedName().Name,
+				},
+				Key:      key,
+				Optional: &optional,
+			},
+		},
+	}
+}
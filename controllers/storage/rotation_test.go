@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGetRetiredAccessKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		data map[string][]byte
+		want []string
+	}{
+		{
+			name: "never rotated",
+			data: map[string][]byte{"accesskey": []byte("core-abc")},
+			want: nil,
+		},
+		{
+			name: "single rotation",
+			data: map[string][]byte{retiredAccessKeys: []byte("core-abc")},
+			want: []string{"core-abc"},
+		},
+		{
+			name: "rotations before harbor is restarted",
+			data: map[string][]byte{retiredAccessKeys: []byte("core-abc,core-def")},
+			want: []string{"core-abc", "core-def"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getRetiredAccessKeys(&corev1.Secret{Data: tt.data}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getRetiredAccessKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
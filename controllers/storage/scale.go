@@ -2,11 +2,17 @@ package storage
 
 import "github.com/goharbor/harbor-cluster-operator/lcm"
 
+// Scale expands the capacity of minIO by appending the new zones, the existing zones are kept as they are.
 func (m *MinIOReconciler) Scale() (*lcm.CRStatus, error) {
 	minioCR := m.CurrentMinIOCR
-	minioCR.Spec.Zones[0].Servers = m.HarborCluster.Spec.Storage.InCluster.Spec.Replicas
+	currentZones := len(minioCR.Spec.Zones)
+	minioCR.Spec.Zones = append(minioCR.Spec.Zones, m.DesiredMinIOCR.Spec.Zones[currentZones:]...)
 
+	m.Log.Info("Expanding minIO", "namespace", minioCR.Namespace, "name", minioCR.Name, "zones", len(minioCR.Spec.Zones))
 	err := m.KubeClient.Update(minioCR)
+	if err != nil {
+		return minioNotReadyStatus(ScaleMinIOError, err.Error()), err
+	}
 
-	return minioUnknownStatus(), err
+	return minioUnknownStatus(), nil
 }
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
+	"github.com/minio/minio/pkg/madmin"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	DefaultUsageReportInterval = time.Hour
+	// usageReportRetryInterval is the delay before collecting the usage again after a failure.
+	usageReportRetryInterval = time.Minute
+
+	// DefaultUsageCollectionBatchDuration is how long the objects of the external storage are listed in a
+	// reconciliation, the collection is resumed in the next one.
+	DefaultUsageCollectionBatchDuration = 10 * time.Second
+	// usageCollectionBatchInterval is the delay before the next batch of the collection in progress.
+	usageCollectionBatchInterval = 5 * time.Second
+)
+
+// getUsageReportInterval returns the interval of the storage usage report, it is 0 if the report is disabled
+// or the usage of the storage kind can not be collected.
+func getUsageReportInterval(harborCluster *goharborv1.HarborCluster) time.Duration {
+	storage := harborCluster.Spec.Storage
+	switch storage.Kind {
+	case inClusterStorage, s3Storage, ossStorage:
+	default:
+		return 0
+	}
+
+	if storage.UsageReportInterval == nil {
+		return DefaultUsageReportInterval
+	}
+	return storage.UsageReportInterval.Duration
+}
+
+// GetUsageReportRequeue returns the delay until the next storage usage report,
+// it returns false if the usage is not reported.
+func GetUsageReportRequeue(harborCluster *goharborv1.HarborCluster, now time.Time) (time.Duration, bool) {
+	interval := getUsageReportInterval(harborCluster)
+	if interval <= 0 {
+		return 0, false
+	}
+
+	if harborCluster.Status.StorageUsageCollection != nil {
+		return usageCollectionBatchInterval, true
+	}
+
+	usage := harborCluster.Status.StorageUsage
+	if usage == nil {
+		return usageReportRetryInterval, true
+	}
+
+	requeueAfter := usage.LastUpdateTime.Add(interval).Sub(now)
+	if requeueAfter < usageReportRetryInterval {
+		requeueAfter = usageReportRetryInterval
+	}
+	return requeueAfter, true
+}
+
+func isUsageReportDue(harborCluster *goharborv1.HarborCluster, now time.Time) bool {
+	interval := getUsageReportInterval(harborCluster)
+	if interval <= 0 {
+		return false
+	}
+
+	usage := harborCluster.Status.StorageUsage
+	return harborCluster.Status.StorageUsageCollection != nil || usage == nil || !now.Before(usage.LastUpdateTime.Add(interval))
+}
+
+// reportStorageUsage collects the usage of the ready storage into the status of the harbor cluster once the report
+// interval elapsed. A failure is only logged, the last collected usage is kept and the collection is retried later.
+func (m *MinIOReconciler) reportStorageUsage() {
+	// the collection in progress is dropped once the usage of the external storage is not reported anymore
+	if m.HarborCluster.Spec.Storage.Kind == inClusterStorage || getUsageReportInterval(m.HarborCluster) <= 0 {
+		m.HarborCluster.Status.StorageUsageCollection = nil
+	}
+	if !isUsageReportDue(m.HarborCluster, time.Now()) {
+		return
+	}
+
+	var usage *goharborv1.StorageUsageStatus
+	var err error
+	if m.HarborCluster.Spec.Storage.Kind == inClusterStorage {
+		usage, err = m.getMinIOUsage()
+	} else {
+		usage, err = m.collectExternalUsage()
+	}
+	if err != nil {
+		m.HarborCluster.Status.StorageUsageCollection = nil
+		m.Log.Error(err, "Failed to collect the storage usage, it will be retried")
+		return
+	}
+
+	if usage != nil {
+		m.HarborCluster.Status.StorageUsage = usage
+	}
+}
+
+// getMinIOUsage returns the usage collected by the data usage crawler of minIO,
+// and the capacity of its drives.
+func (m *MinIOReconciler) getMinIOUsage() (*goharborv1.StorageUsageStatus, error) {
+	ctx, cancel := context.WithTimeout(m.Ctx, minioHealthTimeout)
+	defer cancel()
+
+	dataUsage, err := m.MinioClient.DataUsageInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	storageInfo, err := m.MinioClient.StorageInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &goharborv1.StorageUsageStatus{
+		UsedBytes:      int64(dataUsage.ObjectsTotalSize),
+		Objects:        int64(dataUsage.ObjectsCount),
+		LastUpdateTime: metav1.Now(),
+	}
+	for _, total := range storageInfo.Total {
+		usage.RawCapacityBytes += int64(total)
+	}
+
+	usage.UsableCapacityBytes = usage.RawCapacityBytes
+	if storageInfo.Backend.Type == madmin.Erasure && len(storageInfo.Backend.Sets) > 0 {
+		usage.UsableCapacityBytes = getErasureUsableCapacity(storageInfo)
+	}
+
+	return usage, nil
+}
+
+// getErasureUsableCapacity returns the capacity of the drives available to objects, computed per erasure set since
+// the pools may have different drive counts. The capacities of the drives are listed set by set, in the order of
+// the sets of the pools.
+// minIO only reports the parity of the sets of the first pool, a parity which is not the default of those sets is set
+// by the storage class for every set, otherwise every set has the default parity of half its drives.
+func getErasureUsableCapacity(storageInfo madmin.StorageInfo) int64 {
+	sets := storageInfo.Backend.Sets
+	parity := storageInfo.Backend.StandardSCParity
+	isDefaultParity := parity == 0 || parity == len(sets[0])/2
+
+	var usable int64
+	offset := 0
+	for _, set := range sets {
+		drives := len(set)
+		if drives == 0 || offset+drives > len(storageInfo.Total) {
+			break
+		}
+
+		var raw int64
+		for _, total := range storageInfo.Total[offset : offset+drives] {
+			raw += int64(total)
+		}
+		offset += drives
+
+		setParity := parity
+		if isDefaultParity {
+			setParity = drives / 2
+		}
+		usable += raw / int64(drives) * int64(drives-setParity)
+	}
+	return usable
+}
+
+// collectExternalUsage lists the objects under the root directory of the s3 or oss bucket a page at a time, until all
+// are listed or the batch duration elapses. The collection is resumed after the last listed object in the next
+// reconciliation, and the usage is only returned once every object is listed.
+func (m *MinIOReconciler) collectExternalUsage() (*goharborv1.StorageUsageStatus, error) {
+	target, err := m.getS3Target()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := target.getObjectKey("")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	collection := m.HarborCluster.Status.StorageUsageCollection
+	if collection == nil {
+		collection = &goharborv1.StorageUsageCollectionStatus{StartTime: metav1.Now()}
+		m.HarborCluster.Status.StorageUsageCollection = collection
+	}
+
+	ctx, cancel := context.WithTimeout(m.Ctx, DefaultUsageCollectionBatchDuration)
+	defer cancel()
+	for {
+		result, err := target.listObjectsAfter(ctx, target.Bucket, prefix, collection.LastKey)
+		if ctx.Err() != nil {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			collection.Objects++
+			collection.UsedBytes += object.Size
+			collection.LastKey = object.Key
+		}
+
+		if !result.IsTruncated || len(result.Contents) == 0 {
+			break
+		}
+	}
+
+	m.HarborCluster.Status.StorageUsageCollection = nil
+	return &goharborv1.StorageUsageStatus{
+		UsedBytes:      collection.UsedBytes,
+		Objects:        collection.Objects,
+		LastUpdateTime: metav1.Now(),
+	}, nil
+}
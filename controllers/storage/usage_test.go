@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/minio/minio/pkg/madmin"
+)
+
+func TestGetErasureUsableCapacity(t *testing.T) {
+	const gi = 1 << 30
+	drives := func(count int, size uint64) []uint64 {
+		totals := make([]uint64, count)
+		for i := range totals {
+			totals[i] = size
+		}
+		return totals
+	}
+	storageInfo := func(parity int, setDrives []int, totals ...[]uint64) madmin.StorageInfo {
+		info := madmin.StorageInfo{}
+		info.Backend.Type = madmin.Erasure
+		info.Backend.StandardSCParity = parity
+		for _, count := range setDrives {
+			info.Backend.Sets = append(info.Backend.Sets, make([]madmin.DriveInfo, count))
+		}
+		for _, total := range totals {
+			info.Total = append(info.Total, total...)
+		}
+		return info
+	}
+
+	tests := []struct {
+		name        string
+		storageInfo madmin.StorageInfo
+		want        int64
+	}{
+		{
+			name:        "single set with the default parity",
+			storageInfo: storageInfo(2, []int{4}, drives(4, 10*gi)),
+			want:        20 * gi,
+		},
+		{
+			name:        "single set with the parity of the storage class",
+			storageInfo: storageInfo(2, []int{8}, drives(8, 10*gi)),
+			want:        60 * gi,
+		},
+		{
+			name:        "pools with different drive counts",
+			storageInfo: storageInfo(2, []int{4, 8}, drives(4, 10*gi), drives(8, 20*gi)),
+			want:        20*gi + 80*gi,
+		},
+		{
+			name:        "pools with the parity of the storage class",
+			storageInfo: storageInfo(3, []int{8, 16}, drives(8, 10*gi), drives(16, 10*gi)),
+			want:        50*gi + 130*gi,
+		},
+		{
+			name:        "pool with several erasure sets",
+			storageInfo: storageInfo(2, []int{4, 16, 16}, drives(4, 10*gi), drives(32, 5*gi)),
+			want:        20*gi + 2*40*gi,
+		},
+		{
+			name:        "offline drive reports no capacity",
+			storageInfo: storageInfo(2, []int{4}, []uint64{0, 10 * gi, 10 * gi, 10 * gi}),
+			want:        15 * gi,
+		},
+		{
+			name:        "missing drives are ignored",
+			storageInfo: storageInfo(2, []int{4, 4}, drives(6, 10*gi)),
+			want:        20 * gi,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getErasureUsableCapacity(tt.storageInfo); got != tt.want {
+				t.Errorf("getErasureUsableCapacity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/goharbor/harbor-cluster-operator/controllers/common"
+	minio "github.com/minio/minio-operator/pkg/apis/operator.min.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	DefaultChartMuseumBucket       = "harbor-chartmuseum"
+	DefaultMinIOUserCredsSuffix    = "creds"
+	DefaultMinIOUserPolicySuffix   = "bucket-policy"
+	chartMuseumAmazonStorageKind   = "amazon"
+	chartMuseumAlibabaStorageKind  = "alibaba"
+	chartMuseumDirectory           = "chartmuseum"
+	registryMinIOConsumer          = "registry"
+	chartMuseumMinIOConsumer       = "chartmuseum"
+	minioUserAccessKeyPrefixLength = 8
+	minioUserSecretKeyLength       = 16
+)
+
+// minioConsumer is a harbor component which owns a bucket and a minIO user scoped to the bucket.
+type minioConsumer struct {
+	Name   string
+	Bucket string
+}
+
+// getMinIOConsumers returns the components which store data in minIO,
+// chartmuseum is a consumer only if it is enabled without a separate storage.
+func (m *MinIOReconciler) getMinIOConsumers() []minioConsumer {
+	consumers := []minioConsumer{
+		{Name: registryMinIOConsumer, Bucket: DefaultBucket},
+	}
+	if m.isChartMuseumStoredWithRegistry() {
+		consumers = append(consumers, minioConsumer{Name: chartMuseumMinIOConsumer, Bucket: DefaultChartMuseumBucket})
+	}
+	return consumers
+}
+
+// ensureMinIOUsers makes sure every consumer has its bucket, and a user which can only access this bucket.
+// The bucket is encrypted by default if the encryption of minIO is enabled.
+// The credentials of the users are kept in secrets owned by the minIO instance.
+func (m *MinIOReconciler) ensureMinIOUsers(minioInstance *minio.MinIOInstance) error {
+	for _, consumer := range m.getMinIOConsumers() {
+		exists, err := m.MinioClient.IsBucketExists(consumer.Bucket)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			err = m.MinioClient.CreateBucket(consumer.Bucket)
+			if err != nil {
+				return err
+			}
+		}
+
+		if m.isEncryptionEnabled() {
+			err = m.MinioClient.SetBucketEncryption(consumer.Bucket)
+			if err != nil {
+				return fmt.Errorf("set encryption of minIO bucket %s: %w", consumer.Bucket, err)
+			}
+		}
+
+		accessKey, secretKey, err := m.getOrCreateUserCreds(consumer, minioInstance)
+		if err != nil {
+			return err
+		}
+
+		err = m.MinioClient.AddUser(accessKey, secretKey)
+		if err != nil {
+			return fmt.Errorf("add minIO user of %s: %w", consumer.Name, err)
+		}
+
+		policyName := m.getUserPolicyName(consumer)
+		err = m.MinioClient.AddBucketPolicy(policyName, consumer.Bucket)
+		if err != nil {
+			return fmt.Errorf("add minIO policy %s: %w", policyName, err)
+		}
+
+		err = m.MinioClient.SetUserPolicy(policyName, accessKey)
+		if err != nil {
+			return fmt.Errorf("set minIO policy %s: %w", policyName, err)
+		}
+	}
+
+	return nil
+}
+
+// getOrCreateUserCreds returns the credentials of the consumer user, they are generated at the first time.
+func (m *MinIOReconciler) getOrCreateUserCreds(consumer minioConsumer, minioInstance *minio.MinIOInstance) (string, string, error) {
+	var secret corev1.Secret
+	err := m.KubeClient.Get(m.getUserCredsNamespacedName(consumer), &secret)
+	if k8serror.IsNotFound(err) {
+		userSecret := m.generateUserCredsSecret(consumer, minioInstance)
+		m.Log.Info("Creating minIO user secret", "namespace", userSecret.Namespace, "name", userSecret.Name)
+		err = m.KubeClient.Create(userSecret)
+		if err != nil {
+			return "", "", err
+		}
+		return string(userSecret.Data["accesskey"]), string(userSecret.Data["secretkey"]), nil
+	} else if err != nil {
+		return "", "", err
+	}
+
+	return string(secret.Data["accesskey"]), string(secret.Data["secretkey"]), nil
+}
+
+// getUserCreds returns the credentials of the consumer user.
+func (m *MinIOReconciler) getUserCreds(consumer minioConsumer) (string, string, error) {
+	var secret corev1.Secret
+	err := m.KubeClient.Get(m.getUserCredsNamespacedName(consumer), &secret)
+	return string(secret.Data["accesskey"]), string(secret.Data["secretkey"]), err
+}
+
+func (m *MinIOReconciler) generateUserCredsSecret(consumer minioConsumer, minioInstance *minio.MinIOInstance) *corev1.Secret {
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        m.getUserCredsNamespacedName(consumer).Name,
+			Namespace:   m.HarborCluster.Namespace,
+			Labels:      m.getLabels(),
+			Annotations: m.generateAnnotations(),
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(minioInstance, HarborClusterMinIOGVK),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"accesskey": []byte(consumer.Name + "-" + common.RandomString(minioUserAccessKeyPrefixLength, "a")),
+			"secretkey": []byte(common.RandomString(minioUserSecretKeyLength, "a")),
+		},
+	}
+}
+
+func (m *MinIOReconciler) getUserCredsNamespacedName(consumer minioConsumer) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: m.HarborCluster.Namespace,
+		Name:      fmt.Sprintf("%s-%s-%s", m.getServiceName(), consumer.Name, DefaultMinIOUserCredsSuffix),
+	}
+}
+
+func (m *MinIOReconciler) getUserPolicyName(consumer minioConsumer) string {
+	return fmt.Sprintf("%s-%s-%s", m.HarborCluster.Name, consumer.Name, DefaultMinIOUserPolicySuffix)
+}
+
+// generateInClusterChartMuseumSecret returns the chartmuseum storage secret of the chartmuseum bucket,
+// the keys are loaded as environment variables with and without the STORAGE_ prefix.
+func (m *MinIOReconciler) generateInClusterChartMuseumSecret(minioInstance *minio.MinIOInstance) (*corev1.Secret, error) {
+	consumer := minioConsumer{Name: chartMuseumMinIOConsumer, Bucket: DefaultChartMuseumBucket}
+	accessKey, secretKey, err := m.getUserCreds(consumer)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := m.getLabels()
+	labels[LabelOfStorageType] = inClusterStorage
+
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        m.getServiceName() + "-" + chartMuseumMinIOConsumer,
+			Namespace:   m.HarborCluster.Namespace,
+			Labels:      labels,
+			Annotations: m.generateAnnotations(),
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(minioInstance, HarborClusterMinIOGVK),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"kind":                  []byte(chartMuseumAmazonStorageKind),
+			"AMAZON_BUCKET":         []byte(consumer.Bucket),
+			"AMAZON_REGION":         []byte(DefaultRegion),
+			"AMAZON_ENDPOINT":       []byte(m.getMinIOEndpointURL()),
+			"AWS_ACCESS_KEY_ID":     []byte(accessKey),
+			"AWS_SECRET_ACCESS_KEY": []byte(secretKey),
+		},
+	}, nil
+}
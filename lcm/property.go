@@ -33,6 +33,10 @@ const (
 	SwiftSecretForStorage string = "swiftSecret"
 	S3SecretForStorage    string = "s3Secret"
 	OssSecretForStorage   string = "ossSecret"
+
+	InClusterChartMuseumSecretForStorage string = "inClusterChartMuseumSecret"
+	ExternalChartMuseumSecretForStorage  string = "externalChartMuseumSecret"
+	SeparateChartMuseumSecretForStorage  string = "separateChartMuseumSecret"
 )
 
 //Property is the current property of component.
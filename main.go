@@ -19,6 +19,7 @@ import (
 	"flag"
 	"github.com/goharbor/harbor-operator/api/v1alpha1"
 	"os"
+	"strings"
 	"time"
 
 	goharborv1 "github.com/goharbor/harbor-cluster-operator/api/v1"
@@ -53,10 +54,13 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var requeueAfter time.Duration
+	var secretNamespaces string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
 	flag.DurationVar(&requeueAfter, "requeue-after", 5, "The delay time(second) of Requeue.")
+	flag.StringVar(&secretNamespaces, "secret-namespaces", "",
+		"Comma separated namespaces in which secrets can be referenced by harbor clusters of other namespaces, '*' allows all namespaces.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(func(o *zap.Options) {
@@ -83,6 +87,8 @@ func main() {
 		RequeueAfter:  requeueAfter,
 		ServiceGetter: &controllers.ServiceGetterImpl{},
 		Recorder:      mgr.GetEventRecorderFor("HarborCluster-Controller"),
+
+		AllowedSecretNamespaces: splitNamespaces(secretNamespaces),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "HarborCluster")
 		os.Exit(1)
@@ -99,3 +105,14 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// splitNamespaces splits the comma separated namespaces.
+func splitNamespaces(namespaces string) []string {
+	var result []string
+	for _, ns := range strings.Split(namespaces, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			result = append(result, ns)
+		}
+	}
+	return result
+}